@@ -4,40 +4,168 @@ import (
     "context"
     "fmt"
     "os"
+    "strings"
     "code"
+    "code/formatter"
+    "code/parser"
     cli "github.com/urfave/cli/v3"
 )
 
 
+// stdinArg stands in for "-" while the command line is parsed: urfave/cli
+// stops at a bare "-" and drops every argument after it.
+const stdinArg = "\x00stdin"
+
 func main() {
 	app := newApp()
-	if err := app.Run(context.Background(), os.Args); err != nil {
+	if err := app.Run(context.Background(), escapeStdinArgs(os.Args, app.Flags)); err != nil {
 		os.Exit(1)
 	}
 }
 
 func newApp() *cli.Command {
 	return &cli.Command{
-		Name:      "gendiff",
-		Usage:     "Compares two configuration files and shows a difference.",
-		UsageText: "gendiff [--format stylish] <file1> <file2>",
+		Name:                      "gendiff",
+		Usage:                     "Compares two configuration files and shows a difference.",
+		UsageText:                 "gendiff [--format stylish] [--input-format yaml] <file1|-> <file2|->",
+		DisableSliceFlagSeparator: true,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "format",
 				Aliases: []string{"f"},
-				Usage:   "output format",
+				Usage:   "output format (" + strings.Join(formatter.Formats(), ", ") + ")",
 				Value:   "stylish",
+				Validator: func(format string) error {
+					if _, ok := formatter.Lookup(format); !ok {
+						return fmt.Errorf("unsupported format: %s (expected %s)", format, strings.Join(formatter.Formats(), ", "))
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "input-format",
+				Usage: "parse both files as this format instead of taking it from the extension, or from the content when the extension is unknown (" +
+					strings.Join(parser.Formats(), ", ") + ")",
+			},
+			&cli.BoolFlag{
+				Name:  "ini-types",
+				Usage: "read INI booleans and numbers as typed values instead of strings",
+			},
+			&cli.StringFlag{
+				Name:  "env-nest",
+				Usage: "split .env keys on this separator into nested maps, e.g. '__'",
+			},
+			&cli.BoolFlag{
+				Name:  "properties-nest",
+				Usage: "split dotted .properties keys into nested maps",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "report the format each file was parsed as",
+			},
+			&cli.StringSliceFlag{
+				Name:  "array-key",
+				Usage: "match list elements by a key field, e.g. 'spec.containers=name' (repeatable)",
+			},
+			&cli.BoolFlag{
+				Name:  "match-resources",
+				Usage: "match the documents of multi-document YAML files by apiVersion, kind and metadata.name",
+			},
+			&cli.StringSliceFlag{
+				Name:  "unordered",
+				Usage: "compare lists matching the pattern as sets, '**' for all lists (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore",
+				Usage: "skip paths matching the pattern, e.g. 'metadata.resourceVersion' or '**.lastUpdated' (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "only",
+				Usage: "compare only paths matching the pattern, e.g. 'database.*' (repeatable)",
+			},
+			&cli.StringFlag{
+				Name:  "key-order",
+				Value: code.KeyOrderSorted,
+				Usage: "list keys sorted, or in the order of the first or second file (sorted, first, second)",
+				Validator: func(order string) error {
+					switch order {
+					case code.KeyOrderSorted, code.KeyOrderFirst, code.KeyOrderSecond:
+						return nil
+					}
+					return fmt.Errorf("unsupported key order: %s (expected sorted, first, second)", order)
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "positions",
+				Usage: "show the file and line of each change, e.g. 'a.yaml:12 -> b.yaml:14'",
+			},
+			&cli.BoolFlag{
+				Name:  "group-anchors",
+				Usage: "report a change to a YAML anchor once instead of at every alias or merge key using it",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "fail on duplicate keys, non-string keys and NaN or infinite numbers",
+			},
+			&cli.BoolFlag{
+				Name:  "strict-warn",
+				Usage: "report duplicate keys, non-string keys and NaN or infinite numbers on stderr, then compare anyway",
+			},
+			&cli.BoolFlag{
+				Name:  "detect-moves",
+				Usage: "report values removed in one place and added in another as moves",
+			},
+			&cli.BoolFlag{
+				Name:  "strict-types",
+				Usage: "treat equal values of different types (e.g. 1 and 1.0) as changed",
 			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
 			if cmd.Args().Len() != 2 {
-				return cli.Exit("usage: gendiff [--format stylish] <file1> <file2>", 2)
+				return cli.Exit("usage: gendiff [--format stylish] [--input-format yaml] <file1|-> <file2|->", 2)
 			}
-			f1 := cmd.Args().First()
-			f2 := cmd.Args().Tail()[0]
+			f1 := unescapeStdinArg(cmd.Args().First())
+			f2 := unescapeStdinArg(cmd.Args().Tail()[0])
 			format := cmd.String("format")
 
-			out, err := code.GenDiff(f1, f2, format)
+			diffOpts, err := diffOptions(cmd)
+			if err != nil {
+				return cli.Exit(err.Error(), 2)
+			}
+
+			opts := []code.Option{code.WithDiffOptions(diffOpts...)}
+			if inputFormat := cmd.String("input-format"); inputFormat != "" {
+				opts = append(opts, code.WithInputFormat(inputFormat))
+			}
+			if cmd.Bool("verbose") {
+				opts = append(opts, code.WithVerbose(os.Stderr))
+			}
+			if cmd.Bool("group-anchors") {
+				opts = append(opts, code.WithAnchorReport())
+			}
+			if cmd.Bool("positions") {
+				opts = append(opts, code.WithPositions())
+			}
+			if order := cmd.String("key-order"); order != code.KeyOrderSorted {
+				opts = append(opts, code.WithKeyOrder(order))
+			}
+			if cmd.Bool("ini-types") {
+				opts = append(opts, code.WithDecoder("ini", parser.INIDecoder{CoerceTypes: true}))
+			}
+			if sep := cmd.String("env-nest"); sep != "" {
+				opts = append(opts, code.WithDecoder("dotenv", parser.DotenvDecoder{NestSeparator: sep}))
+			}
+			if cmd.Bool("properties-nest") {
+				opts = append(opts, code.WithDecoder("properties", parser.PropertiesDecoder{ExpandKeys: true}))
+			}
+			if cmd.Bool("strict") {
+				opts = append(opts, code.WithStrict())
+			} else if cmd.Bool("strict-warn") {
+				opts = append(opts, code.WithStrictWarnings(os.Stderr))
+			}
+
+			out, err := code.GenDiff(f1, f2, format, opts...)
 			if err != nil {
 				return cli.Exit(err.Error(), 1)
 			}
@@ -46,3 +174,76 @@ func newApp() *cli.Command {
 		},
 	}
 }
+
+func diffOptions(cmd *cli.Command) ([]formatter.Option, error) {
+	var opts []formatter.Option
+
+	for _, spec := range cmd.StringSlice("array-key") {
+		i := strings.LastIndex(spec, "=")
+		if i <= 0 || i == len(spec)-1 {
+			return nil, fmt.Errorf("invalid --array-key %q: expected <path>=<field>", spec)
+		}
+		opts = append(opts, formatter.WithArrayKey(spec[:i], spec[i+1:]))
+	}
+	if cmd.Bool("match-resources") {
+		opts = append(opts, formatter.WithArrayKey(parser.DocumentsKey, "apiVersion,kind,metadata.name"))
+	}
+
+	if patterns := cmd.StringSlice("unordered"); len(patterns) > 0 {
+		opts = append(opts, formatter.WithUnorderedLists(patterns...))
+	}
+	if patterns := cmd.StringSlice("ignore"); len(patterns) > 0 {
+		opts = append(opts, formatter.WithIgnore(patterns...))
+	}
+	if patterns := cmd.StringSlice("only"); len(patterns) > 0 {
+		opts = append(opts, formatter.WithOnly(patterns...))
+	}
+	if cmd.Bool("strict-types") {
+		opts = append(opts, formatter.WithStrictTypes())
+	}
+	if cmd.Bool("detect-moves") {
+		opts = append(opts, formatter.WithMoveDetection())
+	}
+
+	return opts, nil
+}
+
+// escapeStdinArgs replaces each positional "-" in args, the command line
+// including the program name, with stdinArg. A "-" given as the value of
+// one of flags, e.g. "--env-nest -", is left as it is.
+func escapeStdinArgs(args []string, flags []cli.Flag) []string {
+	takesValue := map[string]bool{}
+	for _, flag := range flags {
+		if f, ok := flag.(cli.DocGenerationFlag); ok && f.TakesValue() {
+			for _, name := range flag.Names() {
+				takesValue[name] = true
+			}
+		}
+	}
+
+	escaped := make([]string, len(args))
+	copy(escaped, args)
+	flagsDone := false
+	for i := 1; i < len(escaped); i++ {
+		arg := escaped[i]
+		switch {
+		case arg == code.StdinPath:
+			escaped[i] = stdinArg
+		case flagsDone:
+		case arg == "--":
+			flagsDone = true
+		case strings.HasPrefix(arg, "-") && !strings.Contains(arg, "="):
+			if takesValue[strings.TrimLeft(arg, "-")] {
+				i++
+			}
+		}
+	}
+	return escaped
+}
+
+func unescapeStdinArg(arg string) string {
+	if arg == stdinArg {
+		return code.StdinPath
+	}
+	return arg
+}
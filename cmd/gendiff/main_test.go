@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeStdinArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no stdin",
+			args: []string{"gendiff", "a.json", "b.json"},
+			want: []string{"gendiff", "a.json", "b.json"},
+		},
+		{
+			name: "first file",
+			args: []string{"gendiff", "-", "b.json"},
+			want: []string{"gendiff", stdinArg, "b.json"},
+		},
+		{
+			name: "after a bool flag",
+			args: []string{"gendiff", "--strict", "a.json", "-"},
+			want: []string{"gendiff", "--strict", "a.json", stdinArg},
+		},
+		{
+			name: "flag value",
+			args: []string{"gendiff", "--env-nest", "-", "a.env", "-"},
+			want: []string{"gendiff", "--env-nest", "-", "a.env", stdinArg},
+		},
+		{
+			name: "flag value by alias",
+			args: []string{"gendiff", "-f", "plain", "-", "b.json"},
+			want: []string{"gendiff", "-f", "plain", stdinArg, "b.json"},
+		},
+		{
+			name: "flag value after equals",
+			args: []string{"gendiff", "--env-nest=-", "-", "b.env"},
+			want: []string{"gendiff", "--env-nest=-", stdinArg, "b.env"},
+		},
+		{
+			name: "after end of flags",
+			args: []string{"gendiff", "--", "--env-nest", "-"},
+			want: []string{"gendiff", "--", "--env-nest", stdinArg},
+		},
+	}
+
+	flags := newApp().Flags
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, escapeStdinArgs(tt.args, flags))
+		})
+	}
+}
+
+func TestUnescapeStdinArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: stdinArg, want: "-"},
+		{arg: "-", want: "-"},
+		{arg: "a.json", want: "a.json"},
+		{arg: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, unescapeStdinArg(tt.arg))
+	}
+}
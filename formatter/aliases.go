@@ -0,0 +1,113 @@
+package formatter
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// maxAliasDepth bounds how many aliases resolveAlias follows, in case the
+// map it is given contains a cycle.
+const maxAliasDepth = 32
+
+// foldAliases drops changes to values that were copied from a YAML anchor
+// when the anchor itself changed the same way, recording their paths in the
+// anchor's Affects instead.
+func (d *differ) foldAliases(diff []*DiffNode) []*DiffNode {
+	index := make(map[string]*DiffNode)
+	indexNodes(diff, index)
+
+	drop := make(map[*DiffNode]bool)
+	for _, node := range walkNodes(diff) {
+		switch node.Type {
+		case Added, Removed, Updated, TypeChanged:
+		default:
+			continue
+		}
+
+		src, ok := resolveAlias(d.aliases, node.Path)
+		if !ok {
+			continue
+		}
+		origin := index[src]
+		if origin == nil || origin == node || !sameChange(origin, node) {
+			continue
+		}
+		origin.Affects = append(origin.Affects, node.Path)
+		drop[node] = true
+	}
+
+	if len(drop) == 0 {
+		return diff
+	}
+	return pruneNodes(diff, drop)
+}
+
+// resolveAlias follows the aliases covering p, or a parent of p, back to the
+// anchored value it was copied from.
+func resolveAlias(aliases map[string]string, p string) (string, bool) {
+	resolved := false
+	for depth := 0; depth < maxAliasDepth; depth++ {
+		segs := splitPath(p)
+		found := false
+		for i := len(segs); i > 0 && !found; i-- {
+			src, ok := aliases[buildPath(segs[:i])]
+			if !ok {
+				continue
+			}
+			p = src
+			for _, seg := range segs[i:] {
+				p = joinPath(p, seg, isIndexSegment(seg))
+			}
+			found = true
+		}
+		if !found {
+			break
+		}
+		resolved = true
+	}
+	return p, resolved
+}
+
+func buildPath(segs []string) string {
+	p := ""
+	for _, seg := range segs {
+		p = joinPath(p, seg, isIndexSegment(seg))
+	}
+	return p
+}
+
+func sameChange(a, b *DiffNode) bool {
+	return a.Type == b.Type &&
+		reflect.DeepEqual(a.Value, b.Value) &&
+		reflect.DeepEqual(a.OldVal, b.OldVal) &&
+		reflect.DeepEqual(a.NewVal, b.NewVal)
+}
+
+func indexNodes(nodes []*DiffNode, index map[string]*DiffNode) {
+	for _, node := range nodes {
+		index[node.Path] = node
+		indexNodes(node.Children, index)
+	}
+}
+
+func walkNodes(nodes []*DiffNode) []*DiffNode {
+	var all []*DiffNode
+	for _, node := range nodes {
+		all = append(all, node)
+		all = append(all, walkNodes(node.Children)...)
+	}
+	return all
+}
+
+// affectsNote is the " (affects N keys)" suffix formatters add to a change
+// that other keys inherited from an anchor.
+func affectsNote(node *DiffNode) string {
+	switch len(node.Affects) {
+	case 0:
+		return ""
+	case 1:
+		return " (affects 1 key)"
+	default:
+		return fmt.Sprintf(" (affects %d keys)", len(node.Affects))
+	}
+}
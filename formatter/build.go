@@ -1,22 +1,55 @@
 package formatter
 
 import (
-	"reflect"
+	"encoding/json"
+	"fmt"
 	"sort"
+	"time"
 )
 
-type DiffNode struct {
-	Type     string      `json:"type"`
-	Key      string      `json:"key"`
-	Value    any         `json:"value,omitempty"`
-	OldVal   any         `json:"old_value,omitempty"`
-	NewVal   any         `json:"new_value,omitempty"`
-	Children []*DiffNode `json:"children,omitempty"`
+type differ struct {
+	options
+
+	// listIndexes maps nodes under a list to the old and new element they
+	// stand for, see recordIndexes. It is only kept when positions are
+	// attached.
+	listIndexes map[*DiffNode][2]int
+}
+
+func BuildDiff(a, b map[string]any, opts ...Option) []*DiffNode {
+	d := &differ{options: newOptions(opts)}
+	positions := d.oldPositions != nil || d.newPositions != nil
+	if positions {
+		d.listIndexes = make(map[*DiffNode][2]int)
+	}
+	if len(d.ignore) > 0 || len(d.only) > 0 {
+		selected := len(d.only) == 0
+		a, b = d.filterMap(a, "", selected), d.filterMap(b, "", selected)
+	}
+
+	diff := d.diffMaps(a, b, "")
+	if len(d.ignore) > 0 || len(d.only) > 0 {
+		dropFiltered(diff)
+	}
+	if len(d.aliases) > 0 {
+		diff = d.foldAliases(diff)
+	}
+	if d.moves {
+		diff = d.detectMoves(diff)
+	}
+	if positions {
+		d.attachPositions(diff, "", "", false)
+	}
+	if d.primaryOrder != nil || d.secondaryOrder != nil {
+		d.attachKeyOrder(diff)
+	}
+	return diff
 }
 
-func BuildDiff(a, b map[string]any) []*DiffNode {
+func (d *differ) diffMaps(a, b map[string]any, path string) []*DiffNode {
 	keys := collectKeys(a, b)
 	sort.Strings(keys)
+	keys = d.orderKeys(path, keys)
 
 	var diff []*DiffNode
 
@@ -24,33 +57,116 @@ func BuildDiff(a, b map[string]any) []*DiffNode {
 		valA, okA := a[key]
 		valB, okB := b[key]
 
+		childPath := joinPath(path, key, false)
+
 		switch {
 		case !okA:
-			diff = append(diff, &DiffNode{Type: "added", Key: key, Value: valB})
+			diff = append(diff, NewAdded(childPath, key, valB))
 
 		case !okB:
-			diff = append(diff, &DiffNode{Type: "removed", Key: key, Value: valA})
-
-		case reflect.DeepEqual(valA, valB):
-			diff = append(diff, &DiffNode{Type: "unchanged", Key: key, Value: valA})
-
-		case isMap(valA) && isMap(valB):
-			children := BuildDiff(valA.(map[string]any), valB.(map[string]any))
-			diff = append(diff, &DiffNode{Type: "nested", Key: key, Children: children})
+			diff = append(diff, NewRemoved(childPath, key, valA))
 
 		default:
-			diff = append(diff, &DiffNode{
-				Type:   "updated",
-				Key:    key,
-				OldVal: valA,
-				NewVal: valB,
-			})
+			diff = append(diff, d.diffValues(key, childPath, valA, valB))
 		}
 	}
 
 	return diff
 }
 
+func (d *differ) diffValues(key, path string, valA, valB any) *DiffNode {
+	switch {
+	case d.equal(valA, valB):
+		return NewUnchanged(path, key, valA)
+
+	case isMap(valA) && isMap(valB):
+		children := d.diffMaps(valA.(map[string]any), valB.(map[string]any), path)
+		if !hasChanges(children) {
+			return NewUnchanged(path, key, valB)
+		}
+		return NewNested(path, key, children)
+
+	case isList(valA) && isList(valB):
+		children := d.diffLists(valA.([]any), valB.([]any), path)
+		if !hasChanges(children) {
+			return NewUnchanged(path, key, valB)
+		}
+		return NewNestedList(path, key, children)
+
+	case d.typeChanged(valA, valB):
+		return NewTypeChanged(path, key, valA, valB)
+
+	case d.strict && looseEqual(valA, valB):
+		// Only the Go type differs, e.g. a JSON 200 and a YAML 200, so
+		// name it rather than print the value as updated to itself.
+		node := NewTypeChanged(path, key, valA, valB)
+		node.OldType, node.NewType = fmt.Sprintf("%T", valA), fmt.Sprintf("%T", valB)
+		return node
+
+	default:
+		return NewUpdated(path, key, valA, valB)
+	}
+}
+
+// typeChanged reports whether a value changed its kind, e.g. from an object
+// to a scalar or from the string "8080" to the number 8080. Changes from or
+// to null are plain updates. Dates and strings are interchangeable unless
+// strict types are requested, since JSON has no date type.
+func (d *differ) typeChanged(valA, valB any) bool {
+	oldType, newType := typeName(valA), typeName(valB)
+	switch {
+	case oldType == newType, oldType == "null", newType == "null":
+		return false
+	case !d.strict && (oldType == "date" || newType == "date"):
+		return oldType != "string" && newType != "string"
+	default:
+		return true
+	}
+}
+
+func typeName(val any) string {
+	switch v := val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return "number"
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "date"
+	case fmt.Stringer:
+		// Decoders with date types of their own, such as TOML's local
+		// dates and times, print them in one of timeLayouts.
+		if _, ok := toTime(v); ok {
+			return "date"
+		}
+		return fmt.Sprintf("%T", val)
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
+
+func hasChanges(nodes []*DiffNode) bool {
+	for _, node := range nodes {
+		switch node.Type {
+		case Unchanged:
+		case Nested:
+			if hasChanges(node.Children) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
 func collectKeys(a, b map[string]any) []string {
 	keys := make(map[string]struct{})
 
@@ -73,3 +189,8 @@ func isMap(val any) bool {
 	_, ok := val.(map[string]any)
 	return ok
 }
+
+func isList(val any) bool {
+	_, ok := val.([]any)
+	return ok
+}
@@ -0,0 +1,135 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// looseEqual compares values the way they read in the source files rather
+// than the way a particular decoder typed them: 200 from YAML (int), TOML
+// (int64) and JSON (float64) are equal, and so are a YAML timestamp, a TOML
+// local date and the same date written as a JSON string.
+func looseEqual(a, b any) bool {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for k, x := range va {
+			y, ok := vb[k]
+			if !ok || !looseEqual(x, y) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		vb, ok := b.([]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !looseEqual(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if ra, ok := toRat(a); ok {
+		rb, ok := toRat(b)
+		return ok && ra.Cmp(rb) == 0
+	}
+
+	if !isString(a) || !isString(b) {
+		if ta, ok := toTime(a); ok {
+			tb, ok := toTime(b)
+			return ok && ta.Equal(tb)
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toRat(v any) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case uint:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint8:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float32:
+		return decimalRat(strconv.FormatFloat(float64(n), 'g', -1, 32))
+	case float64:
+		return decimalRat(strconv.FormatFloat(n, 'g', -1, 64))
+	case json.Number:
+		return decimalRat(string(n))
+	}
+	return nil, false
+}
+
+// decimalRat is the exact value of a decimal number. Floats are taken at
+// the shortest decimal that reads back as them, which is how they were
+// written, so they compare like the json.Numbers the parser keeps for the
+// decimals a float cannot hold. Numbers beyond the range of a float64, and
+// NaN and infinities, have no value here and compare as they are.
+func decimalRat(text string) (*big.Rat, bool) {
+	if f, err := strconv.ParseFloat(text, 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
+
+func toTime(v any) (time.Time, bool) {
+	var s string
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		s = t
+	case fmt.Stringer:
+		s = t.String()
+	default:
+		return time.Time{}, false
+	}
+
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isString(v any) bool {
+	_, ok := v.(string)
+	return ok
+}
@@ -0,0 +1,134 @@
+package formatter
+
+// filterMap returns a copy of m without the paths excluded by the options.
+// Filtering the inputs rather than the finished tree keeps ignored fields
+// out of list element comparisons and out of unchanged or added values.
+// selected is true once an ancestor of m matched one of the only patterns.
+func (o options) filterMap(m map[string]any, path string, selected bool) map[string]any {
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		if val, ok := o.filterEntry(v, joinPath(path, k, false), selected); ok {
+			result[k] = val
+		}
+	}
+	return result
+}
+
+func (o options) filterEntry(v any, path string, selected bool) (any, bool) {
+	if o.isIgnored(path) {
+		return nil, false
+	}
+
+	if !selected {
+		switch {
+		case matchAny(o.only, path):
+			selected = true
+		case !matchAnyPrefix(o.only, path):
+			return nil, false
+		}
+	}
+
+	filtered := o.filterValue(v, path, selected)
+	if !selected && isEmpty(filtered) {
+		return nil, false
+	}
+	return filtered, true
+}
+
+// filteredOut stands in for a list element the options exclude, so that the
+// elements after it keep their index. The list diffs skip it, and
+// dropFiltered removes it from the values left in the finished tree.
+type filteredOut struct{}
+
+func (o options) filterValue(v any, path string, selected bool) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return o.filterMap(val, path, selected)
+	case []any:
+		return o.filterList(val, path, selected)
+	default:
+		return v
+	}
+}
+
+// filterList filters the elements of list, replacing those left out by a
+// placeholder. Each element is addressed by the path it has in the diff:
+// "[field=value]" when the list is keyed, see WithArrayKey, and its index
+// otherwise. When nothing is left, the result is empty rather than a list of
+// placeholders.
+func (o options) filterList(list []any, path string, selected bool) []any {
+	var keys []string
+	if field, ok := o.arrayKeyFor(path); ok {
+		keys, _ = elementKeys(list, field)
+	}
+
+	result := make([]any, len(list))
+	kept := 0
+	for i, elem := range list {
+		elemPath := joinPath(path, indexKey(i), true)
+		if keys != nil && keys[i] != "" {
+			elemPath = joinPath(path, keys[i], true)
+		}
+		if filtered, ok := o.filterEntry(elem, elemPath, selected); ok {
+			result[i] = filtered
+			kept++
+		} else {
+			result[i] = filteredOut{}
+		}
+	}
+	if kept == 0 {
+		return []any{}
+	}
+	return result
+}
+
+func isFilteredOut(v any) bool {
+	_, ok := v.(filteredOut)
+	return ok
+}
+
+// dropFiltered removes the placeholders of filtered out list elements from
+// the values of nodes, which are shown as they are.
+func dropFiltered(nodes []*DiffNode) {
+	for _, node := range nodes {
+		node.Value = withoutFiltered(node.Value)
+		node.OldVal = withoutFiltered(node.OldVal)
+		node.NewVal = withoutFiltered(node.NewVal)
+		dropFiltered(node.Children)
+	}
+}
+
+func withoutFiltered(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(val))
+		for k, x := range val {
+			result[k] = withoutFiltered(x)
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(val))
+		for _, x := range val {
+			if !isFilteredOut(x) {
+				result = append(result, withoutFiltered(x))
+			}
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// isEmpty reports whether v has nothing left to compare: a scalar or an
+// empty container. It is used to drop the ancestors of only patterns that
+// turned out not to contain any matching path.
+func isEmpty(v any) bool {
+	switch val := v.(type) {
+	case map[string]any:
+		return len(val) == 0
+	case []any:
+		return len(val) == 0
+	default:
+		return true
+	}
+}
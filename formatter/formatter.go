@@ -1,16 +1,91 @@
 package formatter
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
 
-func Format(diff []*DiffNode, format string) (string, error) {
-	switch format {
-	case "stylish":
+// Formatter renders a diff tree built by BuildDiff.
+type Formatter interface {
+	Format(diff []*DiffNode) (string, error)
+}
+
+// FormatterFunc adapts a plain function to the Formatter interface.
+type FormatterFunc func(diff []*DiffNode) (string, error)
+
+func (f FormatterFunc) Format(diff []*DiffNode) (string, error) {
+	return f(diff)
+}
+
+type registeredFormatter struct {
+	name      string
+	formatter Formatter
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registeredFormatter
+)
+
+func init() {
+	Register("stylish", FormatterFunc(func(diff []*DiffNode) (string, error) {
 		return FormatStylish(diff, 0), nil
-	case "plain":
+	}))
+	Register("plain", FormatterFunc(func(diff []*DiffNode) (string, error) {
 		return FormatPlain(diff, ""), nil
-	case "json":
-		return FormatJSON(diff)
-	default:
-		return "", fmt.Errorf("unknown format: %s", format)
+	}))
+	Register("json", FormatterFunc(FormatJSON))
+}
+
+// Register makes an output format available to Format under name.
+// Registering a name again replaces the earlier formatter.
+func Register(name string, f Formatter) {
+	if name == "" || f == nil {
+		panic("formatter: Register needs a format name and a formatter")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for i := range registry {
+		if registry[i].name == name {
+			registry[i].formatter = f
+			return
+		}
+	}
+	registry = append(registry, registeredFormatter{name: name, formatter: f})
+}
+
+// Formats returns the names of the registered output formats in the order
+// they were registered.
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for _, r := range registry {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+func Lookup(name string) (Formatter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, r := range registry {
+		if r.name == name {
+			return r.formatter, true
+		}
+	}
+	return nil, false
+}
+
+func Format(diff []*DiffNode, format string) (string, error) {
+	f, ok := Lookup(format)
+	if !ok {
+		return "", fmt.Errorf("unsupported format: %s (expected %s)", format, strings.Join(Formats(), ", "))
 	}
+	return f.Format(diff)
 }
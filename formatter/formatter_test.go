@@ -2,7 +2,10 @@ package formatter
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,8 +25,8 @@ func TestBuildDiff(t *testing.T) {
 			a:    map[string]any{"host": testHost},
 			b:    map[string]any{"host": testHost, "timeout": 50},
 			expected: []*DiffNode{
-				{Type: "unchanged", Key: "host", Value: testHost},
-				{Type: "added", Key: "timeout", Value: 50},
+				{Type: Unchanged, Key: "host", Value: testHost},
+				{Type: Added, Key: "timeout", Value: 50},
 			},
 		},
 		{
@@ -31,8 +34,8 @@ func TestBuildDiff(t *testing.T) {
 			a:    map[string]any{"host": testHost, "timeout": 50},
 			b:    map[string]any{"host": testHost},
 			expected: []*DiffNode{
-				{Type: "unchanged", Key: "host", Value: testHost},
-				{Type: "removed", Key: "timeout", Value: 50},
+				{Type: Unchanged, Key: "host", Value: testHost},
+				{Type: Removed, Key: "timeout", Value: 50},
 			},
 		},
 		{
@@ -40,7 +43,7 @@ func TestBuildDiff(t *testing.T) {
 			a:    map[string]any{"timeout": 50},
 			b:    map[string]any{"timeout": 20},
 			expected: []*DiffNode{
-				{Type: "updated", Key: "timeout", OldVal: 50, NewVal: 20},
+				{Type: Updated, Key: "timeout", OldVal: 50, NewVal: 20},
 			},
 		},
 		{
@@ -53,11 +56,11 @@ func TestBuildDiff(t *testing.T) {
 			},
 			expected: []*DiffNode{
 				{
-					Type: "nested",
+					Type: Nested,
 					Key:  "common",
 					Children: []*DiffNode{
-						{Type: "unchanged", Key: "setting1", Value: "value1"},
-						{Type: "updated", Key: "setting2", OldVal: 200.0, NewVal: 300.0},
+						{Type: Unchanged, Key: "setting1", Value: "value1"},
+						{Type: Updated, Key: "setting2", OldVal: 200.0, NewVal: 300.0},
 					},
 				},
 			},
@@ -90,17 +93,17 @@ func TestBuildDiff(t *testing.T) {
 			},
 			expected: []*DiffNode{
 				{
-					Type: "nested",
+					Type: Nested,
 					Key:  "common",
 					Children: []*DiffNode{
-						{Type: "updated", Key: "follow", OldVal: false, NewVal: true},
-						{Type: "unchanged", Key: "setting1", Value: "value1"},
+						{Type: Updated, Key: "follow", OldVal: false, NewVal: true},
+						{Type: Unchanged, Key: "setting1", Value: "value1"},
 					},
 				},
-				{Type: "removed", Key: "debug", Value: false},
-				{Type: "unchanged", Key: "host", Value: testHost},
-				{Type: "updated", Key: "timeout", OldVal: 50, NewVal: 20},
-				{Type: "added", Key: "verbose", Value: true},
+				{Type: Removed, Key: "debug", Value: false},
+				{Type: Unchanged, Key: "host", Value: testHost},
+				{Type: Updated, Key: "timeout", OldVal: 50, NewVal: 20},
+				{Type: Added, Key: "verbose", Value: true},
 			},
 		},
 	}
@@ -119,16 +122,16 @@ func TestBuildDiff(t *testing.T) {
 				assert.Equal(t, expectedNode.Key, actualNode.Key, "Ключ узла не совпадает")
 
 				switch expectedNode.Type {
-				case "added":
+				case Added:
 					assert.Equal(t, expectedNode.Value, actualNode.Value, "Значение добавленного узла не совпадает для ключа %s", expectedNode.Key)
-				case "removed":
+				case Removed:
 					assert.Equal(t, expectedNode.Value, actualNode.Value, "Значение удалённого узла не совпадает для ключа %s", expectedNode.Key)
-				case "updated":
+				case Updated:
 					assert.Equal(t, expectedNode.OldVal, actualNode.OldVal, "Старое значение не совпадает для ключа %s", expectedNode.Key)
 					assert.Equal(t, expectedNode.NewVal, actualNode.NewVal, "Новое значение не совпадает для ключа %s", expectedNode.Key)
-				case "unchanged":
+				case Unchanged:
 					assert.Equal(t, expectedNode.Value, actualNode.Value, "Значение неизменённого узла не совпадает для ключа %s", expectedNode.Key)
-				case "nested":
+				case Nested:
 					assert.NotNil(t, actualNode.Children, "Дети вложенного узла должны быть не nil для ключа %s", expectedNode.Key)
 					assert.Len(t, actualNode.Children, len(expectedNode.Children), "Количество детей не совпадает для ключа %s", expectedNode.Key)
 				}
@@ -234,7 +237,7 @@ func TestFormatPlain(t *testing.T) {
 		{
 			name: "added property",
 			nodes: []*DiffNode{
-				{Type: "added", Key: "timeout", Value: 50},
+				{Type: Added, Key: "timeout", Value: 50},
 			},
 			path:     "",
 			expected: "Property 'timeout' was added with value: 50",
@@ -242,7 +245,7 @@ func TestFormatPlain(t *testing.T) {
 		{
 			name: "removed property",
 			nodes: []*DiffNode{
-				{Type: "removed", Key: "debug", Value: false},
+				{Type: Removed, Key: "debug", Value: false},
 			},
 			path:     "",
 			expected: "Property 'debug' was removed",
@@ -250,7 +253,7 @@ func TestFormatPlain(t *testing.T) {
 		{
 			name: "updated property",
 			nodes: []*DiffNode{
-				{Type: "updated", Key: "timeout", OldVal: 50, NewVal: 20},
+				{Type: Updated, Key: "timeout", OldVal: 50, NewVal: 20},
 			},
 			path:     "",
 			expected: "Property 'timeout' was updated. From 50 to 20",
@@ -259,11 +262,11 @@ func TestFormatPlain(t *testing.T) {
 			name: "nested property",
 			nodes: []*DiffNode{
 				{
-					Type: "nested",
+					Type: Nested,
 					Key:  "common",
 					Children: []*DiffNode{
-						{Type: "added", Key: "follow", Value: true},
-						{Type: "added", Key: "setting5", Value: map[string]any{"key": "val"}},
+						{Type: Added, Key: "follow", Value: true},
+						{Type: Added, Key: "setting5", Value: map[string]any{"key": "val"}},
 					},
 				},
 			},
@@ -273,8 +276,8 @@ func TestFormatPlain(t *testing.T) {
 		{
 			name: "multiple changes",
 			nodes: []*DiffNode{
-				{Type: "removed", Key: "debug", Value: false},
-				{Type: "added", Key: "verbose", Value: true},
+				{Type: Removed, Key: "debug", Value: false},
+				{Type: Added, Key: "verbose", Value: true},
 			},
 			path:     "",
 			expected: "Property 'debug' was removed\nProperty 'verbose' was added with value: true",
@@ -339,15 +342,15 @@ func TestASTStructure(t *testing.T) {
 	require.Len(t, diff, 1)
 
 	root := diff[0]
-	assert.Equal(t, "nested", root.Type)
+	assert.Equal(t, Nested, root.Type)
 	assert.Equal(t, "common", root.Key)
 	require.Len(t, root.Children, 2)
 
 	child1 := root.Children[0]
 	child2 := root.Children[1]
 
-	assert.Equal(t, "unchanged", child1.Type)
-	assert.Equal(t, "updated", child2.Type)
+	assert.Equal(t, Unchanged, child1.Type)
+	assert.Equal(t, Updated, child2.Type)
 	assert.Equal(t, 200, child2.OldVal)
 	assert.Equal(t, 300, child2.NewVal)
 }
@@ -361,17 +364,17 @@ func TestFormatJSON(t *testing.T) {
 		{
 			name: "simple added",
 			nodes: []*DiffNode{
-				{Type: "added", Key: "timeout", Value: 50},
+				{Type: Added, Key: "timeout", Value: 50},
 			},
 		},
 		{
 			name: "nested structure",
 			nodes: []*DiffNode{
 				{
-					Type: "nested",
+					Type: Nested,
 					Key:  "common",
 					Children: []*DiffNode{
-						{Type: "added", Key: "follow", Value: true},
+						{Type: Added, Key: "follow", Value: true},
 					},
 				},
 			},
@@ -391,3 +394,761 @@ func TestFormatJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildDiff_Lists(t *testing.T) {
+	a := map[string]any{
+		"hosts": []any{"a", "b", "c", "d"},
+		"servers": []any{
+			map[string]any{"name": "web", "port": 80},
+			map[string]any{"name": "api", "port": 8080},
+		},
+	}
+	b := map[string]any{
+		"hosts": []any{"a", "c", "d", "e"},
+		"servers": []any{
+			map[string]any{"name": "web", "port": 80},
+			map[string]any{"name": "api", "port": 9090},
+		},
+	}
+
+	diff := BuildDiff(a, b)
+	require.Len(t, diff, 2)
+
+	hosts := diff[0]
+	assert.Equal(t, Nested, hosts.Type)
+	assert.True(t, hosts.Array)
+	require.Len(t, hosts.Children, 5)
+	assert.Equal(t, NewUnchanged("hosts[0]", "[0]", "a"), hosts.Children[0])
+	assert.Equal(t, NewRemoved("hosts[1]", "[1→]", "b"), hosts.Children[1])
+	assert.Equal(t, NewUnchanged("hosts[1]", "[2→1]", "c"), hosts.Children[2])
+	assert.Equal(t, NewAdded("hosts[3]", "[→3]", "e"), hosts.Children[4])
+
+	servers := diff[1]
+	require.Len(t, servers.Children, 2)
+	assert.Equal(t, Unchanged, servers.Children[0].Type)
+	api := servers.Children[1]
+	assert.Equal(t, Nested, api.Type)
+	assert.Equal(t, "[1]", api.Key)
+	require.Len(t, api.Children, 2)
+	assert.Equal(t, NewUpdated("servers[1].port", "port", 8080, 9090), api.Children[1])
+}
+
+func TestBuildDiff_ListReplacedElement(t *testing.T) {
+	a := map[string]any{"list": []any{1, 2, 3}}
+	b := map[string]any{"list": []any{1, 5, 3}}
+
+	diff := BuildDiff(a, b)
+	require.Len(t, diff, 1)
+	require.Len(t, diff[0].Children, 3)
+	assert.Equal(t, NewUpdated("list[1]", "[1]", 2, 5), diff[0].Children[1])
+}
+
+func TestFormatLists(t *testing.T) {
+	a := map[string]any{
+		"servers": []any{
+			map[string]any{"port": 80},
+			map[string]any{"port": 8080},
+		},
+	}
+	b := map[string]any{
+		"servers": []any{
+			map[string]any{"port": 80},
+			map[string]any{"port": 9090},
+			"extra",
+		},
+	}
+	diff := BuildDiff(a, b)
+
+	plain := FormatPlain(diff, "")
+	assert.Equal(t, "Property 'servers[1].port' was updated. From 8080 to 9090\n"+
+		"Property 'servers[2]' was added with value: 'extra'", plain)
+
+	stylish := FormatStylish(diff, 0)
+	assert.Equal(t, `{
+    servers: [
+        [0]: {
+            port: 80
+        }
+        [1]: {
+          - port: 8080
+          + port: 9090
+        }
+      + [→2]: extra
+    ]
+}`, stylish)
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"array": true`)
+	assert.Contains(t, out, `"key": "[→2]"`)
+}
+
+func TestBuildDiff_KeyedLists(t *testing.T) {
+	a := map[string]any{
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "web", "image": "nginx:1.0"},
+				map[string]any{"name": "worker", "image": "worker:1"},
+				map[string]any{"name": "cron", "image": "cron:1"},
+			},
+		},
+	}
+	b := map[string]any{
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "sidecar", "image": "envoy"},
+				map[string]any{"name": "web", "image": "nginx:1.1"},
+				map[string]any{"name": "worker", "image": "worker:1"},
+			},
+		},
+	}
+
+	diff := BuildDiff(a, b, WithArrayKey("spec.containers", "name"))
+	assert.Equal(t, "Property 'spec.containers[name=sidecar]' was added with value: [complex value]\n"+
+		"Property 'spec.containers[name=web].image' was updated. From 'nginx:1.0' to 'nginx:1.1'\n"+
+		"Property 'spec.containers[name=cron]' was removed", FormatPlain(diff, ""))
+}
+
+func TestBuildDiff_KeyedListsFallback(t *testing.T) {
+	a := map[string]any{"jobs": []any{map[string]any{"id": 1}, map[string]any{"other": 2}}}
+	b := map[string]any{"jobs": []any{map[string]any{"id": 1}, map[string]any{"other": 3}}}
+
+	diff := BuildDiff(a, b, WithArrayKey("jobs", "id"))
+	assert.Equal(t, "Property 'jobs[1].other' was updated. From 2 to 3", FormatPlain(diff, ""))
+
+	a = map[string]any{"jobs": []any{map[string]any{"other": 2}, map[string]any{"id": 1, "v": 1}}}
+	b = map[string]any{"jobs": []any{map[string]any{"id": 1, "v": 2}, map[string]any{"other": 3}, "extra"}}
+	diff = BuildDiff(a, b, WithArrayKey("jobs", "id"))
+	assert.Equal(t, "Property 'jobs[id=1].v' was updated. From 1 to 2\n"+
+		"Property 'jobs[1].other' was updated. From 2 to 3\n"+
+		"Property 'jobs[2]' was added with value: 'extra'", FormatPlain(diff, ""))
+
+	dup := BuildDiff(map[string]any{"jobs": []any{map[string]any{"id": 1}, map[string]any{"id": 1.0}}},
+		map[string]any{"jobs": []any{map[string]any{"id": 1}}}, WithArrayKey("jobs", "id"))
+	assert.Equal(t, "Property 'jobs[1]' was removed", FormatPlain(dup, ""))
+}
+
+func TestBuildDiff_KeyedListsTypedKeys(t *testing.T) {
+	a := map[string]any{"jobs": []any{map[string]any{"id": 1, "v": "x"}}}
+	b := map[string]any{"jobs": []any{map[string]any{"id": "1", "v": "x"}, map[string]any{"id": 1.0, "v": "y"}}}
+
+	diff := BuildDiff(a, b, WithArrayKey("jobs", "id"))
+	assert.Equal(t, "Property 'jobs[id=\"1\"]' was added with value: [complex value]\n"+
+		"Property 'jobs[id=1].v' was updated. From 'x' to 'y'", FormatPlain(diff, ""))
+
+	names := BuildDiff(map[string]any{"flags": []any{map[string]any{"name": true}, map[string]any{"name": nil}}},
+		map[string]any{"flags": []any{map[string]any{"name": "true"}, map[string]any{"name": "null"}}},
+		WithArrayKey("flags", "name"))
+	assert.Equal(t, "Property 'flags[name=\"true\"]' was added with value: [complex value]\n"+
+		"Property 'flags[name=\"null\"]' was added with value: [complex value]\n"+
+		"Property 'flags[name=true]' was removed\n"+
+		"Property 'flags[name=null]' was removed", FormatPlain(names, ""))
+}
+
+func TestBuildDiff_CompositeArrayKey(t *testing.T) {
+	service := func(kind, name string, port int) map[string]any {
+		return map[string]any{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]any{"name": name},
+			"spec":       map[string]any{"port": port},
+		}
+	}
+	a := map[string]any{"documents": []any{service("Service", "web", 80), service("Service", "db", 5432)}}
+	b := map[string]any{"documents": []any{service("Service", "db", 5432), service("Service", "web", 8080)}}
+
+	diff := BuildDiff(a, b, WithArrayKey("documents", "apiVersion,kind,metadata.name"))
+	assert.Equal(t, "Property 'documents[apiVersion=v1,kind=Service,metadata.name=web].spec.port' was updated. From 80 to 8080",
+		FormatPlain(diff, ""))
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"spec.containers", "spec.containers", true},
+		{"spec.containers", "spec.initContainers", false},
+		{"spec.*", "spec.containers", true},
+		{"*.lastUpdated", "status.lastUpdated", true},
+		{"*.lastUpdated", "a.b.lastUpdated", false},
+		{"**.lastUpdated", "a.b.lastUpdated", true},
+		{"**", "anything.at[3].all", true},
+		{"servers[*].port", "servers[3].port", true},
+		{"servers[*].port", "servers[name=web].port", true},
+		{"servers[1].port", "servers[3].port", false},
+		{"servers.*.port", "servers[3].port", true},
+		{"build.time*", "build.timestamp", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchPath(tt.pattern, tt.path))
+		})
+	}
+}
+
+func TestBuildDiff_UnorderedLists(t *testing.T) {
+	a := map[string]any{
+		"flags": []any{"a", "b", "c"},
+		"net":   map[string]any{"allow": []any{"10.0.0.0/8", "192.168.0.0/16"}},
+		"order": []any{1, 2},
+	}
+	b := map[string]any{
+		"flags": []any{"c", "d", "a"},
+		"net":   map[string]any{"allow": []any{"192.168.0.0/16", "10.0.0.0/8"}},
+		"order": []any{2, 1},
+	}
+
+	diff := BuildDiff(a, b, WithUnorderedLists("flags", "net.allow"))
+	require.Len(t, diff, 3)
+
+	flags := diff[0]
+	assert.Equal(t, Nested, flags.Type)
+	assert.Equal(t, []*DiffNode{
+		NewUnchanged("flags[0]", "[2→0]", "c"),
+		NewAdded("flags[1]", "[→1]", "d"),
+		NewUnchanged("flags[2]", "[0→2]", "a"),
+		NewRemoved("flags[1]", "[1→]", "b"),
+	}, flags.Children)
+
+	assert.Equal(t, NewUnchanged("net", "net", b["net"]), diff[1])
+	assert.Equal(t, Nested, diff[2].Type, "Порядок в обычных списках должен учитываться")
+
+	all := BuildDiff(a, b, WithUnorderedLists("**"))
+	assert.Equal(t, "Property 'flags[1]' was added with value: 'd'\nProperty 'flags[1]' was removed",
+		FormatPlain(all, ""))
+}
+
+func TestBuildDiff_CrossFormatValues(t *testing.T) {
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fromJSON := map[string]any{
+		"port":    200.0,
+		"ratio":   0.5,
+		"created": "2024-01-02T03:04:05Z",
+		"day":     "2024-01-02",
+		"list":    []any{1.0, 2.0},
+	}
+	fromYAML := map[string]any{
+		"port":    200,
+		"ratio":   0.5,
+		"created": stamp,
+		"day":     time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"list":    []any{int64(1), uint64(2)},
+	}
+
+	diff := BuildDiff(fromJSON, fromYAML)
+	assert.False(t, hasChanges(diff), "Одинаковые значения из разных форматов должны совпадать")
+
+	strict := BuildDiff(fromJSON, fromYAML, WithStrictTypes())
+	plain := FormatPlain(strict, "")
+	assert.Contains(t, plain, "Property 'port' changed type from float64 to int. From 200 to 200")
+	assert.Contains(t, plain, "Property 'list[1]' changed type from float64 to uint64. From 2 to 2")
+	assert.NotContains(t, plain, "ratio")
+	assert.Contains(t, FormatStylish(strict, 0), "  + port: 200 (type changed from float64 to int)")
+}
+
+func TestLooseEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        any
+		b        any
+		expected bool
+	}{
+		{"int and float", 200, 200.0, true},
+		{"int64 and uint", int64(7), uint(7), true},
+		{"different numbers", 200, 200.5, false},
+		{"number and string", 200, "200", false},
+		{"equal strings", "a", "a", true},
+		{"date strings are compared literally", "2024-01-02T00:00:00Z", "2024-01-02T00:00:00+00:00", false},
+		{"time and string", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "2024-01-02", true},
+		{"time and other string", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "tomorrow", false},
+		{"nested maps", map[string]any{"a": []any{1}}, map[string]any{"a": []any{1.0}}, true},
+		{"maps of different size", map[string]any{"a": 1}, map[string]any{"a": 1, "b": 2}, false},
+		{"nil values", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, looseEqual(tt.a, tt.b))
+		})
+	}
+}
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+func TestTypeName(t *testing.T) {
+	assert.Equal(t, "number", typeName(json.Number("12345678901234567890")))
+	assert.Equal(t, "date", typeName(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "date", typeName(testStringer("2024-01-02")))
+	assert.Equal(t, "formatter.testStringer", typeName(testStringer("10.0.0.1")))
+
+	diff := BuildDiff(map[string]any{"addr": "10.0.0.1"}, map[string]any{"addr": testStringer("10.0.0.2")})
+	assert.Equal(t, "Property 'addr' changed type from string to formatter.testStringer. From '10.0.0.1' to 10.0.0.2",
+		FormatPlain(diff, ""))
+}
+
+func TestBuildDiff_TypeChanged(t *testing.T) {
+	a := map[string]any{
+		"port":  "8080",
+		"nest":  map[string]any{"key": "value"},
+		"empty": nil,
+		"count": 1,
+	}
+	b := map[string]any{
+		"port":  8080,
+		"nest":  "str",
+		"empty": "now set",
+		"count": 2.5,
+	}
+
+	diff := BuildDiff(a, b)
+	require.Len(t, diff, 4)
+	assert.Equal(t, Updated, diff[0].Type)
+	assert.Equal(t, Updated, diff[1].Type, "Изменение с null не считается сменой типа")
+	assert.Equal(t, &DiffNode{
+		Type: TypeChanged, Key: "nest", Path: "nest",
+		OldVal: a["nest"], NewVal: "str",
+		OldType: "object", NewType: "string",
+	}, diff[2])
+	assert.Equal(t, TypeChanged, diff[3].Type)
+
+	assert.Equal(t, "Property 'count' was updated. From 1 to 2.5\n"+
+		"Property 'empty' was updated. From null to 'now set'\n"+
+		"Property 'nest' changed type from object to string. From [complex value] to 'str'\n"+
+		"Property 'port' changed type from string to number. From '8080' to 8080", FormatPlain(diff, ""))
+
+	stylish := FormatStylish(diff, 0)
+	assert.Contains(t, stylish, "  - port: 8080\n  + port: 8080 (type changed from string to number)")
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"status": "typeChanged"`)
+	assert.Contains(t, out, `"oldType": "string"`)
+	assert.Contains(t, out, `"newType": "number"`)
+}
+
+func TestBuildDiff_Ignore(t *testing.T) {
+	a := map[string]any{
+		"metadata": map[string]any{"name": "api", "resourceVersion": "100"},
+		"build":    map[string]any{"timestamp": 1, "version": "1.0"},
+		"items": []any{
+			map[string]any{"id": 1, "lastUpdated": "mon"},
+			map[string]any{"id": 2, "lastUpdated": "mon"},
+		},
+	}
+	b := map[string]any{
+		"metadata": map[string]any{"name": "api", "resourceVersion": "205"},
+		"build":    map[string]any{"timestamp": 2, "version": "1.1"},
+		"items": []any{
+			map[string]any{"id": 1, "lastUpdated": "tue"},
+			map[string]any{"id": 3, "lastUpdated": "tue"},
+		},
+	}
+
+	diff := BuildDiff(a, b, WithIgnore("metadata.resourceVersion", "build.time*", "**.lastUpdated"))
+
+	assert.Equal(t, "Property 'build.version' was updated. From '1.0' to '1.1'\n"+
+		"Property 'items[1].id' was updated. From 2 to 3", FormatPlain(diff, ""))
+	assert.NotContains(t, FormatStylish(diff, 0), "lastUpdated")
+	assert.NotContains(t, FormatStylish(diff, 0), "resourceVersion")
+
+	byIndex := BuildDiff(a, b, WithIgnore("items[1]", "items[*].lastUpdated", "metadata", "build"))
+	assert.Empty(t, FormatPlain(byIndex, ""))
+
+	first := BuildDiff(a, b, WithIgnore("items[0]", "items[*].lastUpdated", "metadata", "build"))
+	assert.Equal(t, "Property 'items[1].id' was updated. From 2 to 3", FormatPlain(first, ""))
+
+	added := BuildDiff(a, map[string]any{"items": []any{map[string]any{"id": 0}, map[string]any{"id": 2}, map[string]any{"id": 4}}},
+		WithIgnore("items[0]", "items[*].lastUpdated", "metadata", "build"))
+	assert.Equal(t, "Property 'items[2]' was added with value: [complex value]", FormatPlain(added, ""))
+	assert.Contains(t, FormatStylish(BuildDiff(a, b, WithIgnore("items[0]", "metadata")), 0), "      + lastUpdated: tue")
+	assert.Equal(t, []any{map[string]any{"id": 2, "lastUpdated": "mon"}}, BuildDiff(a, a, WithIgnore("items[0]"))[1].Value)
+}
+
+func TestBuildDiff_Only(t *testing.T) {
+	a := map[string]any{
+		"database": map[string]any{"host": "db1", "port": 5432},
+		"services": map[string]any{
+			"api":    map[string]any{"replicas": 2},
+			"worker": map[string]any{"replicas": 1},
+		},
+		"logging": "info",
+		"servers": []any{map[string]any{"port": 80}, map[string]any{"port": 81}},
+	}
+	b := map[string]any{
+		"database": map[string]any{"host": "db2", "port": 5432},
+		"services": map[string]any{
+			"api":    map[string]any{"replicas": 3},
+			"worker": map[string]any{"replicas": 5},
+		},
+		"logging": "debug",
+		"servers": []any{map[string]any{"port": 8080}, map[string]any{"port": 81}},
+	}
+
+	diff := BuildDiff(a, b, WithOnly("database.*", "services.api", "missing.path"))
+	assert.Equal(t, "Property 'database.host' was updated. From 'db1' to 'db2'\n"+
+		"Property 'services.api.replicas' was updated. From 2 to 3", FormatPlain(diff, ""))
+	assert.Equal(t, `{
+    database: {
+      - host: db1
+      + host: db2
+        port: 5432
+    }
+    services: {
+        api: {
+          - replicas: 2
+          + replicas: 3
+        }
+    }
+}`, FormatStylish(diff, 0))
+
+	lists := BuildDiff(a, b, WithOnly("servers[*].port"), WithIgnore("servers[1]"))
+	assert.Equal(t, "Property 'servers[0].port' was updated. From 80 to 8080", FormatPlain(lists, ""))
+}
+
+func TestBuildDiff_FilterKeyedLists(t *testing.T) {
+	a := map[string]any{"servers": []any{
+		map[string]any{"name": "a", "port": 80, "host": "x"},
+		map[string]any{"name": "b", "port": 81, "host": "y"},
+	}}
+	b := map[string]any{"servers": []any{
+		map[string]any{"name": "c", "port": 82, "host": "z"},
+		map[string]any{"name": "a", "port": 8080, "host": "x"},
+		map[string]any{"name": "b", "port": 8181, "host": "w"},
+	}}
+
+	ignored := BuildDiff(a, b, WithArrayKey("servers", "name"), WithIgnore("servers[name=b].port", "servers[name=c]"))
+	assert.Equal(t, "Property 'servers[name=a].port' was updated. From 80 to 8080\n"+
+		"Property 'servers[name=b].host' was updated. From 'y' to 'w'", FormatPlain(ignored, ""))
+
+	only := BuildDiff(a, b, WithArrayKey("servers", "name"), WithOnly("servers[name=b]"))
+	assert.Equal(t, "Property 'servers[name=b].host' was updated. From 'y' to 'w'\n"+
+		"Property 'servers[name=b].port' was updated. From 81 to 8181", FormatPlain(only, ""))
+}
+
+func TestBuildDiff_MoveDetection(t *testing.T) {
+	a := map[string]any{
+		"db_host": "db.internal",
+		"db_port": 5432,
+		"enabled": true,
+		"legacy":  map[string]any{"timeout": 30, "retries": 3},
+		"flag1":   "on",
+		"flag2":   "on",
+	}
+	b := map[string]any{
+		"database": map[string]any{"host": "db.internal", "port": 6432},
+		"active":   true,
+		"client":   map[string]any{"timeout": 30, "retries": 3},
+		"feature":  "on",
+	}
+
+	diff := BuildDiff(a, b, WithMoveDetection())
+
+	assert.Equal(t, "Property 'active' was added with value: true\n"+
+		"Property 'legacy' was moved to 'client'\n"+
+		"Property 'db_host' was moved to 'database.host'\n"+
+		"Property 'database.port' was added with value: 6432\n"+
+		"Property 'db_port' was removed\n"+
+		"Property 'enabled' was removed\n"+
+		"Property 'feature' was added with value: 'on'\n"+
+		"Property 'flag1' was removed\n"+
+		"Property 'flag2' was removed", FormatPlain(diff, ""))
+
+	stylish := FormatStylish(diff, 0)
+	assert.Contains(t, stylish, "  + active: true\n")
+	assert.Contains(t, stylish, "      + host: db.internal (moved from db_host)")
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"status": "moved"`)
+	assert.Contains(t, out, `"from": "db_host"`)
+	assert.Contains(t, out, `"to": "database.host"`)
+
+	withoutMoves := BuildDiff(a, b)
+	assert.NotContains(t, FormatPlain(withoutMoves, ""), "was moved")
+
+	trivial := BuildDiff(
+		map[string]any{"env": "prod", "replicas": 3, "debug": false, "tags": []any{}},
+		map[string]any{"stage": "prod", "workers": 3, "verbose": false, "labels": []any{}},
+		WithMoveDetection())
+	assert.NotContains(t, FormatPlain(trivial, ""), "was moved")
+}
+
+func TestBuildDiff_Aliases(t *testing.T) {
+	a := map[string]any{
+		"defaults": map[string]any{"image": "app:1"},
+		"web":      map[string]any{"image": "app:1", "port": 80},
+		"worker":   map[string]any{"image": "app:1"},
+		"api":      map[string]any{"image": "app:1"},
+	}
+	b := map[string]any{
+		"defaults": map[string]any{"image": "app:2"},
+		"web":      map[string]any{"image": "app:2", "port": 80},
+		"worker":   map[string]any{"image": "app:2"},
+		"api":      map[string]any{"image": "app:3"},
+	}
+	aliases := map[string]string{
+		"web.image": "defaults.image",
+		"worker":    "defaults",
+		"api.image": "defaults.image",
+	}
+
+	diff := BuildDiff(a, b, WithAliases(aliases))
+	assert.Equal(t, "Property 'api.image' was updated. From 'app:1' to 'app:3'\n"+
+		"Property 'defaults.image' was updated. From 'app:1' to 'app:2' (affects 2 keys)", FormatPlain(diff, ""))
+	assert.Equal(t, []string{"web.image", "worker.image"}, diff[1].Children[0].Affects)
+	assert.Contains(t, FormatStylish(diff, 0), "+ image: app:2 (affects 2 keys)")
+}
+
+func TestBuildDiff_Positions(t *testing.T) {
+	a := map[string]any{
+		"db":      map[string]any{"host": "x"},
+		"servers": []any{map[string]any{"name": "a", "port": 80}},
+	}
+	b := map[string]any{
+		"db":      map[string]any{"host": "y", "user": "root"},
+		"servers": []any{map[string]any{"name": "new"}, map[string]any{"name": "a", "port": 81}},
+	}
+	oldPos := map[string]Position{
+		"db": {"a.yaml", 1, 1}, "db.host": {"a.yaml", 2, 3},
+		"servers": {"a.yaml", 3, 1}, "servers[0]": {"a.yaml", 4, 3}, "servers[0].port": {"a.yaml", 4, 5},
+	}
+	newPos := map[string]Position{
+		"db": {"b.yaml", 1, 1}, "db.host": {"b.yaml", 2, 3}, "db.user": {"b.yaml", 3, 3},
+		"servers": {"b.yaml", 4, 1}, "servers[0]": {"b.yaml", 5, 3}, "servers[1]": {"b.yaml", 6, 3},
+	}
+
+	diff := BuildDiff(a, b, WithArrayKey("servers", "name"), WithPositions(oldPos, newPos))
+	assert.Equal(t, "Property 'db.host' was updated. From 'x' to 'y' (a.yaml:2 -> b.yaml:2)\n"+
+		"Property 'db.user' was added with value: 'root' (b.yaml:3)\n"+
+		"Property 'servers[name=new]' was added with value: [complex value] (b.yaml:5)\n"+
+		"Property 'servers[name=a].port' was updated. From 80 to 81 (a.yaml:4 -> b.yaml:6)", FormatPlain(diff, ""))
+
+	plain := BuildDiff(a, b)
+	assert.NotContains(t, FormatPlain(plain, ""), "a.yaml")
+}
+
+func TestBuildDiff_KeyOrder(t *testing.T) {
+	a := map[string]any{"version": 3, "services": map[string]any{"web": 1, "db": 1}, "zeta": 1}
+	b := map[string]any{"version": 3, "services": map[string]any{"web": 2, "cache": 1, "db": 1}, "alpha": 1, "zeta": 1}
+	first := map[string][]string{"": {"version", "services", "zeta"}, "services": {"web", "db"}}
+	second := map[string][]string{"": {"version", "services", "alpha", "zeta"}, "services": {"web", "cache", "db"}}
+
+	diff := BuildDiff(a, b, WithKeyOrder(first, second))
+	assert.Equal(t, "{\n    version: 3\n    services: {\n      - web: 1\n      + web: 2\n      + cache: 1\n        db: 1\n    }\n"+
+		"  + alpha: 1\n    zeta: 1\n}", FormatStylish(diff, 0))
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Less(t, strings.Index(out, `"version"`), strings.Index(out, `"alpha"`))
+	assert.Less(t, strings.Index(out, `"alpha"`), strings.Index(out, `"zeta"`))
+
+	sorted := BuildDiff(a, b)
+	assert.Equal(t, []string{"alpha", "services", "version", "zeta"}, nodeKeys(sorted))
+}
+
+func TestFormat_KeyOrderValues(t *testing.T) {
+	a := map[string]any{"db": "sqlite"}
+	b := map[string]any{"db": map[string]any{"port": 5432, "host": "x", "auth": map[string]any{"user": "u", "pass": "p"}}}
+	order := map[string][]string{"": {"db"}, "db": {"port", "host", "auth"}, "db.auth": {"user", "pass"}}
+
+	diff := BuildDiff(a, b, WithKeyOrder(nil, order))
+	assert.Equal(t, "{\n  - db: sqlite\n  + db: {\n        port: 5432\n        host: x\n        auth: {\n"+
+		"            user: u\n            pass: p\n        }\n    } (type changed from string to object)\n}", FormatStylish(diff, 0))
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Less(t, strings.Index(out, `"port"`), strings.Index(out, `"host"`))
+	assert.Less(t, strings.Index(out, `"host"`), strings.Index(out, `"auth"`))
+	assert.Less(t, strings.Index(out, `"user"`), strings.Index(out, `"pass"`))
+
+	sorted, err := FormatJSON(BuildDiff(a, b))
+	require.NoError(t, err)
+	assert.Less(t, strings.Index(sorted, `"auth"`), strings.Index(sorted, `"host"`))
+}
+
+func TestFormatJSON_DuplicateKeys(t *testing.T) {
+	_, err := FormatJSON([]*DiffNode{NewAdded("a", "a", 1), NewRemoved("a", "a", 2)})
+	assert.ErrorContains(t, err, `duplicate key "a"`)
+}
+
+func nodeKeys(nodes []*DiffNode) []string {
+	keys := make([]string, len(nodes))
+	for i, node := range nodes {
+		keys[i] = node.Key
+	}
+	return keys
+}
+
+func TestChangeKind(t *testing.T) {
+	for _, kind := range []ChangeKind{Added, Removed, Unchanged, Updated, TypeChanged, Moved, Nested} {
+		parsed, err := ParseChangeKind(kind.String())
+		require.NoError(t, err)
+		assert.Equal(t, kind, parsed)
+	}
+
+	assert.Equal(t, "typeChanged", TypeChanged.String())
+	assert.Equal(t, "ChangeKind(0)", ChangeKind(0).String())
+
+	_, err := ParseChangeKind("renamed")
+	assert.Error(t, err)
+
+	_, err = json.Marshal(&DiffNode{Key: "x"})
+	assert.Error(t, err, "Узел без типа не должен сериализоваться")
+}
+
+func TestDiffNode_JSONRoundTrip(t *testing.T) {
+	a := map[string]any{
+		"common":  map[string]any{"setting1": "value1", "setting2": 200.0},
+		"servers": []any{"a", "b"},
+	}
+	b := map[string]any{
+		"common":  map[string]any{"setting1": "value1", "setting2": "300"},
+		"servers": []any{"a", "c"},
+	}
+	diff := BuildDiff(a, b)
+
+	data, err := json.Marshal(diff)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"typeChanged"`)
+	assert.Contains(t, string(data), `"path":"common.setting2"`)
+
+	var decoded []*DiffNode
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, diff, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`[{"type":"bogus","key":"x"}]`), &decoded))
+}
+
+func TestBuildDiff_Paths(t *testing.T) {
+	a := map[string]any{"servers": []any{map[string]any{"port": 80}}}
+	b := map[string]any{"servers": []any{map[string]any{"port": 8080}}}
+
+	diff := BuildDiff(a, b)
+	require.Len(t, diff, 1)
+	assert.Equal(t, "servers", diff[0].Path)
+	assert.Equal(t, "servers[0]", diff[0].Children[0].Path)
+	assert.Equal(t, "servers[0].port", diff[0].Children[0].Children[0].Path)
+}
+
+func TestFormatRegistry(t *testing.T) {
+	assert.Equal(t, []string{"stylish", "plain", "json"}, Formats()[:3])
+
+	diff := BuildDiff(map[string]any{"timeout": 50}, map[string]any{"timeout": 20})
+
+	for _, name := range []string{"stylish", "plain", "json"} {
+		out, err := Format(diff, name)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, out, name)
+	}
+
+	_, err := Format(diff, "xml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported format: xml (expected stylish, plain, json")
+
+	Register("count", FormatterFunc(func(diff []*DiffNode) (string, error) {
+		return fmt.Sprintf("%d change(s)", len(diff)), nil
+	}))
+	out, err := Format(diff, "count")
+	require.NoError(t, err)
+	assert.Equal(t, "1 change(s)", out)
+
+	f, ok := Lookup("count")
+	require.True(t, ok)
+	out, err = f.Format(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "0 change(s)", out)
+
+	assert.Panics(t, func() { Register("broken", nil) })
+}
+
+func TestBuildDiff_ExactNumbers(t *testing.T) {
+	a := map[string]any{
+		"id":    json.Number("9007199254740993"),
+		"ratio": 0.1,
+		"count": json.Number("18446744073709551616"),
+		"limit": int64(9007199254740993),
+		"max":   float64(9007199254740992),
+	}
+	b := map[string]any{
+		"id":    json.Number("9007199254740992"),
+		"ratio": json.Number("0.10"),
+		"count": json.Number("18446744073709551616.0"),
+		"limit": json.Number("9007199254740993"),
+		"max":   float64(18014398509481984),
+	}
+	diff := BuildDiff(a, b)
+
+	assert.Equal(t, "Property 'id' was updated. From 9007199254740993 to 9007199254740992\n"+
+		"Property 'max' was updated. From 9007199254740992 to 18014398509481984", FormatPlain(diff, ""))
+	assert.Equal(t, `{
+    count: 18446744073709551616
+  - id: 9007199254740993
+  + id: 9007199254740992
+    limit: 9007199254740993
+  - max: 9007199254740992
+  + max: 18014398509481984
+    ratio: 0.1
+}`, FormatStylish(diff, 0))
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	assert.Contains(t, out, `"oldValue": 9007199254740993`)
+	assert.Contains(t, out, `"newValue": 9007199254740992`)
+
+	huge := BuildDiff(map[string]any{"n": json.Number("1e400")}, map[string]any{"n": json.Number("1e400")})
+	require.Len(t, huge, 1)
+	assert.Equal(t, Unchanged, huge[0].Type)
+}
+
+func TestFormat_ListRemovalInTheMiddle(t *testing.T) {
+	a := map[string]any{"hosts": []any{"a", "b", "c", "d"}}
+	b := map[string]any{"hosts": []any{"a", "c", "d", "e"}}
+	diff := BuildDiff(a, b)
+
+	assert.Equal(t, `{
+    hosts: [
+        [0]: a
+      - [1→]: b
+        [2→1]: c
+        [3→2]: d
+      + [→3]: e
+    ]
+}`, FormatStylish(diff, 0))
+	assert.Equal(t, "Property 'hosts[1]' was removed\nProperty 'hosts[3]' was added with value: 'e'", FormatPlain(diff, ""))
+
+	out, err := FormatJSON(diff)
+	require.NoError(t, err)
+	var decoded map[string]struct {
+		Children []struct {
+			Key    string `json:"key"`
+			Status string `json:"status"`
+			Value  any    `json:"value"`
+		} `json:"children"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	hosts := decoded["hosts"].Children
+	require.Len(t, hosts, 5)
+	assert.Equal(t, "[1→]", hosts[1].Key)
+	assert.Equal(t, "removed", hosts[1].Status)
+	assert.Equal(t, "b", hosts[1].Value)
+
+	unordered := BuildDiff(
+		map[string]any{"tags": []any{"a", "b", "c"}},
+		map[string]any{"tags": []any{"c", "x"}},
+		WithUnorderedLists("tags"),
+	)
+	out, err = FormatJSON(unordered)
+	require.NoError(t, err)
+	decoded = nil
+	require.NoError(t, json.Unmarshal([]byte(out), &decoded))
+	var got []string
+	for _, elem := range decoded["tags"].Children {
+		got = append(got, elem.Status+" "+elem.Key+" "+fmt.Sprint(elem.Value))
+	}
+	assert.Equal(t, []string{"unchanged [2→0] c", "added [→1] x", "removed [0→] a", "removed [1→] b"}, got)
+}
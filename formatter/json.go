@@ -1,76 +1,171 @@
 package formatter
 
 import (
+    "bytes"
     "encoding/json"
+    "fmt"
 )
 
+// jsonNode is a DiffNode in the JSON output. Key is only set on list
+// elements, which are written as a JSON array rather than an object.
 type jsonNode struct {
-    Status   string `json:"status"`
-    Value    any    `json:"value,omitempty"`
-    OldValue any    `json:"oldValue,omitempty"`
-    NewValue any    `json:"newValue,omitempty"`
-    Children any    `json:"children,omitempty"`
+    Key      string    `json:"key,omitempty"`
+    Status   string    `json:"status"`
+    Value    any       `json:"value,omitempty"`
+    OldValue any       `json:"oldValue,omitempty"`
+    NewValue any       `json:"newValue,omitempty"`
+    OldType  string    `json:"oldType,omitempty"`
+    NewType  string    `json:"newType,omitempty"`
+    From     string    `json:"from,omitempty"`
+    To       string    `json:"to,omitempty"`
+    Array    bool      `json:"array,omitempty"`
+    Children any       `json:"children,omitempty"`
+    Affects  []string  `json:"affects,omitempty"`
+    OldPos   *Position `json:"oldPos,omitempty"`
+    NewPos   *Position `json:"newPos,omitempty"`
+}
+
+// jsonObject is a JSON object that keeps its keys in the order they are set:
+// the order of the diff for nodes, the key order of the diff for values.
+type jsonObject struct {
+    keys   []string
+    values map[string]any
+}
+
+func newJSONObject(size int) *jsonObject {
+    return &jsonObject{keys: make([]string, 0, size), values: make(map[string]any, size)}
+}
+
+// set adds key to o. Keys are unique among the siblings of a diff, so a key
+// set twice is a bug in the diff, reported rather than written out once.
+func (o *jsonObject) set(key string, value any) error {
+    if _, ok := o.values[key]; ok {
+        return fmt.Errorf("duplicate key %q in JSON output", key)
+    }
+    o.keys = append(o.keys, key)
+    o.values[key] = value
+    return nil
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    for i, key := range o.keys {
+        if i > 0 {
+            buf.WriteByte(',')
+        }
+        k, err := json.Marshal(key)
+        if err != nil {
+            return nil, err
+        }
+        v, err := json.Marshal(o.values[key])
+        if err != nil {
+            return nil, err
+        }
+        buf.Write(k)
+        buf.WriteByte(':')
+        buf.Write(v)
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
 }
 
 func FormatJSON(nodes []*DiffNode) (string, error) {
-    jsonData := convertToJSONNode(nodes)
-    bytes, err := json.MarshalIndent(jsonData, "", "    ")
+    jsonData, err := convertToJSONNode(nodes)
+    if err != nil {
+        return "", err
+    }
+    out, err := json.MarshalIndent(jsonData, "", "    ")
     if err != nil {
         return "", err
     }
-    return string(bytes), nil
+    return string(out), nil
 }
 
-func convertToJSONNode(nodes []*DiffNode) map[string]*jsonNode {
-    result := make(map[string]*jsonNode)
+func convertToJSONNode(nodes []*DiffNode) (*jsonObject, error) {
+    result := newJSONObject(len(nodes))
     for _, node := range nodes {
-        jsonN := &jsonNode{}
-        switch node.Type {
-        case "added":
-            jsonN.Status = "added"
-            jsonN.Value = convertValue(node.Value)
-        case "removed":
-            jsonN.Status = "removed"
-            jsonN.Value = convertValue(node.Value)
-        case "unchanged":
-            jsonN.Status = "unchanged"
-            jsonN.Value = convertValue(node.Value)
-        case "updated":
-            jsonN.Status = "updated"
-            jsonN.OldValue = convertValue(node.OldVal)
-            jsonN.NewValue = convertValue(node.NewVal)
-        case "nested":
-            jsonN.Status = "nested"
-            childrenMap := convertToJSONNode(node.Children)
-            if len(childrenMap) > 0 {
-                jsonN.Children = childrenMap
-            }
+        jsonN, err := convertNode(node)
+        if err != nil {
+            return nil, err
+        }
+        if err := result.set(node.Key, jsonN); err != nil {
+            return nil, err
         }
-        result[node.Key] = jsonN
     }
-    return result
+    return result, nil
 }
 
-func convertValue(value any) any {
-    switch v := value.(type) {
-    case map[string]any:
-        return convertMap(v)
-    case nil:
-        return nil
-    default:
-        return v
+func convertToJSONList(nodes []*DiffNode) ([]*jsonNode, error) {
+    elems := make([]*jsonNode, len(nodes))
+    for i, node := range nodes {
+        elem, err := convertNode(node)
+        if err != nil {
+            return nil, err
+        }
+        elem.Key = node.Key
+        elems[i] = elem
     }
+    return elems, nil
 }
 
-func convertMap(m map[string]any) map[string]any {
-    result := make(map[string]any)
-    for k, v := range m {
-        switch val := v.(type) {
-        case map[string]any:
-            result[k] = convertMap(val)
-        default:
-            result[k] = val
+func convertNode(node *DiffNode) (*jsonNode, error) {
+    jsonN := &jsonNode{Status: node.Type.String()}
+    value := func(v any) any {
+        return convertValue(v, node.Path, node.KeyOrder)
+    }
+    switch node.Type {
+    case Added, Removed, Unchanged:
+        jsonN.Value = value(node.Value)
+    case Updated:
+        jsonN.OldValue = value(node.OldVal)
+        jsonN.NewValue = value(node.NewVal)
+    case TypeChanged:
+        jsonN.OldValue = value(node.OldVal)
+        jsonN.NewValue = value(node.NewVal)
+        jsonN.OldType = node.OldType
+        jsonN.NewType = node.NewType
+    case Moved:
+        jsonN.Value = value(node.Value)
+        jsonN.From = node.From
+        jsonN.To = node.To
+    case Nested:
+        jsonN.Array = node.Array
+        if node.Array {
+            elems, err := convertToJSONList(node.Children)
+            if err != nil {
+                return nil, err
+            }
+            if len(elems) > 0 {
+                jsonN.Children = elems
+            }
+        } else {
+            childrenMap, err := convertToJSONNode(node.Children)
+            if err != nil {
+                return nil, err
+            }
+            if len(childrenMap.keys) > 0 {
+                jsonN.Children = childrenMap
+            }
         }
     }
+    jsonN.Affects = node.Affects
+    jsonN.OldPos = node.OldPos
+    jsonN.NewPos = node.NewPos
+    return jsonN, nil
+}
+
+// convertValue converts value, the value at path, listing the keys of maps
+// as order gives them.
+func convertValue(value any, path string, order map[string][]string) any {
+    m, ok := value.(map[string]any)
+    if !ok {
+        return value
+    }
+    result := newJSONObject(len(m))
+    for _, k := range mapKeys(m, path, order) {
+        // The keys of a map are unique, so set cannot fail.
+        _ = result.set(k, convertValue(m[k], joinPath(path, k, false), order))
+    }
     return result
 }
@@ -0,0 +1,59 @@
+package formatter
+
+import "fmt"
+
+// ChangeKind says what happened to a value between the two inputs. The zero
+// value is not a valid kind, so a DiffNode built without one is easy to spot.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota + 1
+	Removed
+	Unchanged
+	Updated
+	TypeChanged
+	Moved
+	Nested
+)
+
+var changeKindNames = map[ChangeKind]string{
+	Added:       "added",
+	Removed:     "removed",
+	Unchanged:   "unchanged",
+	Updated:     "updated",
+	TypeChanged: "typeChanged",
+	Moved:       "moved",
+	Nested:      "nested",
+}
+
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+func ParseChangeKind(s string) (ChangeKind, error) {
+	for kind, name := range changeKindNames {
+		if name == s {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown change kind: %s", s)
+}
+
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	if _, ok := changeKindNames[k]; !ok {
+		return nil, fmt.Errorf("unknown change kind: %d", int(k))
+	}
+	return []byte(k.String()), nil
+}
+
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	kind, err := ParseChangeKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
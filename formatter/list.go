@@ -0,0 +1,350 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type listOpKind int
+
+const (
+	opEqual listOpKind = iota
+	opRemove
+	opAdd
+)
+
+type listOp struct {
+	kind listOpKind
+	i, j int
+}
+
+func (d *differ) diffLists(a, b []any, path string) []*DiffNode {
+	if field, ok := d.arrayKeyFor(path); ok {
+		if diff, ok := d.diffKeyedLists(a, b, path, field); ok {
+			return diff
+		}
+	}
+	if d.isUnordered(path) {
+		return d.diffUnorderedLists(a, b, path)
+	}
+
+	var diff []*DiffNode
+	var removed, added []int
+
+	flush := func() {
+		paired := min(len(removed), len(added))
+		for k := 0; k < paired; k++ {
+			i, j := removed[k], added[k]
+			node := d.diffValues(elementKey(i, j), joinPath(path, indexKey(j), true), a[i], b[j])
+			diff = append(diff, d.recordIndexes(node, i, j))
+		}
+		for _, i := range removed[paired:] {
+			diff = append(diff, d.removedElement(path, a, i))
+		}
+		for _, j := range added[paired:] {
+			diff = append(diff, d.addedElement(path, b, j))
+		}
+		removed, added = nil, nil
+	}
+
+	for _, op := range lcsScript(a, b, d.equal) {
+		switch {
+		case op.kind == opEqual:
+			flush()
+			if !isFilteredOut(b[op.j]) {
+				diff = append(diff, d.unchangedElement(path, b, op.i, op.j))
+			}
+		case op.kind == opRemove && !isFilteredOut(a[op.i]):
+			removed = append(removed, op.i)
+		case op.kind == opAdd && !isFilteredOut(b[op.j]):
+			added = append(added, op.j)
+		}
+	}
+	flush()
+
+	return diff
+}
+
+// diffKeyedLists pairs list elements by the value of field, so inserting an
+// element at the top of the list does not shift every following one.
+// Elements without a usable key are paired by position among themselves. It
+// reports false when a key is repeated, in which case the caller falls back
+// to the positional diff.
+func (d *differ) diffKeyedLists(a, b []any, path, field string) ([]*DiffNode, bool) {
+	keysA, ok := elementKeys(a, field)
+	if !ok {
+		return nil, false
+	}
+	keysB, ok := elementKeys(b, field)
+	if !ok {
+		return nil, false
+	}
+
+	indexA := make(map[string]int, len(keysA))
+	for i, k := range keysA {
+		indexA[k] = i
+	}
+	indexB := make(map[string]int, len(keysB))
+	for j, k := range keysB {
+		indexB[k] = j
+	}
+
+	var diff []*DiffNode
+	var keylessA, keylessB []int
+	for j, key := range keysB {
+		switch i, found := indexA[key]; {
+		case key == "":
+			if !isFilteredOut(b[j]) {
+				keylessB = append(keylessB, j)
+			}
+		case found:
+			diff = append(diff, d.recordIndexes(d.diffValues(key, joinPath(path, key, true), a[i], b[j]), i, j))
+		default:
+			diff = append(diff, d.recordIndexes(NewAdded(joinPath(path, key, true), key, b[j]), -1, j))
+		}
+	}
+	for i, key := range keysA {
+		if _, found := indexB[key]; key == "" && !isFilteredOut(a[i]) {
+			keylessA = append(keylessA, i)
+		} else if !found && key != "" {
+			diff = append(diff, d.recordIndexes(NewRemoved(joinPath(path, key, true), key, a[i]), i, -1))
+		}
+	}
+
+	paired := min(len(keylessA), len(keylessB))
+	for k := 0; k < paired; k++ {
+		i, j := keylessA[k], keylessB[k]
+		node := d.diffValues(elementKey(i, j), joinPath(path, indexKey(j), true), a[i], b[j])
+		diff = append(diff, d.recordIndexes(node, i, j))
+	}
+	for _, i := range keylessA[paired:] {
+		diff = append(diff, d.removedElement(path, a, i))
+	}
+	for _, j := range keylessB[paired:] {
+		diff = append(diff, d.addedElement(path, b, j))
+	}
+
+	return diff, true
+}
+
+func (d *differ) diffUnorderedLists(a, b []any, path string) []*DiffNode {
+	used := make([]bool, len(a))
+	var diff []*DiffNode
+
+	for j, elem := range b {
+		if isFilteredOut(elem) {
+			continue
+		}
+		match := -1
+		for i := range a {
+			if !used[i] && d.equal(a[i], elem) {
+				used[i] = true
+				match = i
+				break
+			}
+		}
+
+		if match >= 0 {
+			diff = append(diff, d.unchangedElement(path, b, match, j))
+		} else {
+			diff = append(diff, d.addedElement(path, b, j))
+		}
+	}
+	for i := range a {
+		if !used[i] && !isFilteredOut(a[i]) {
+			diff = append(diff, d.removedElement(path, a, i))
+		}
+	}
+
+	return diff
+}
+
+// unchangedElement, addedElement and removedElement build the node of a list
+// element found at index i of the old list and j of the new one. Its path
+// is where the element is in its own file; its key is an elementKey.
+func (d *differ) unchangedElement(path string, b []any, i, j int) *DiffNode {
+	return d.recordIndexes(NewUnchanged(joinPath(path, indexKey(j), true), elementKey(i, j), b[j]), i, j)
+}
+
+func (d *differ) addedElement(path string, b []any, j int) *DiffNode {
+	return d.recordIndexes(NewAdded(joinPath(path, indexKey(j), true), elementKey(-1, j), b[j]), -1, j)
+}
+
+func (d *differ) removedElement(path string, a []any, i int) *DiffNode {
+	return d.recordIndexes(NewRemoved(joinPath(path, indexKey(i), true), elementKey(i, -1), a[i]), i, -1)
+}
+
+// elementKeys returns the key of each element of list, such as
+// "[name=web]". field may name several comma-separated fields, each of them a
+// dotted path into the element, e.g. "apiVersion,kind,metadata.name", which
+// gives keys like "[apiVersion=v1,kind=Service,metadata.name=web]".
+// Elements that lack one of the fields, or hold an object or list there, get
+// an empty key, as do elements left out by WithIgnore or WithOnly. It reports
+// false when two elements have the same key.
+func elementKeys(list []any, field string) ([]string, bool) {
+	fields := strings.Split(field, ",")
+	keys := make([]string, len(list))
+	seen := make(map[string]struct{}, len(list))
+
+	for i, elem := range list {
+		m, ok := elem.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		parts := make([]string, len(fields))
+		for n, f := range fields {
+			val, ok := fieldValue(m, f)
+			if !ok || isMap(val) || isList(val) {
+				parts = nil
+				break
+			}
+			parts[n] = f + "=" + keyValue(val)
+		}
+		if parts == nil {
+			continue
+		}
+
+		k := "[" + strings.Join(parts, ",") + "]"
+		if _, dup := seen[k]; dup {
+			return nil, false
+		}
+		seen[k] = struct{}{}
+		keys[i] = k
+	}
+
+	return keys, true
+}
+
+// keyValue writes a key field so that values equal as looseEqual sees them
+// read the same, 1, 1.0 and json.Number("1") all as 1, and values of
+// different kinds read differently: the strings "1", "true" and "null" are
+// quoted.
+func keyValue(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case string:
+		if _, err := strconv.ParseFloat(v, 64); err == nil || v == "" || v == "true" || v == "false" || v == "null" {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+
+	if r, ok := toRat(val); ok {
+		if r.IsInt() {
+			return r.Num().String()
+		}
+		f, _ := r.Float64()
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(val)
+}
+
+// fieldValue looks up field in m, first as a key and then as a dotted path
+// through nested maps.
+func fieldValue(m map[string]any, field string) (any, bool) {
+	if val, ok := m[field]; ok {
+		return val, true
+	}
+
+	parts := strings.Split(field, ".")
+	var cur any = m
+	for _, part := range parts {
+		node, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = node[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// lcsScript returns the shortest edit script turning a into b. The common
+// prefix and suffix are matched up front so the quadratic LCS table only
+// covers the part of the lists that actually changed.
+func lcsScript(a, b []any, equal func(x, y any) bool) []listOp {
+	start := 0
+	for start < len(a) && start < len(b) && equal(a[start], b[start]) {
+		start++
+	}
+
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && equal(a[endA-1], b[endB-1]) {
+		endA--
+		endB--
+	}
+
+	n, m := endA-start, endB-start
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(a[start+i], b[start+j]) {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]listOp, 0, len(a)+len(b))
+	for k := 0; k < start; k++ {
+		ops = append(ops, listOp{kind: opEqual, i: k, j: k})
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case equal(a[start+i], b[start+j]):
+			ops = append(ops, listOp{kind: opEqual, i: start + i, j: start + j})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			ops = append(ops, listOp{kind: opRemove, i: start + i})
+			i++
+		default:
+			ops = append(ops, listOp{kind: opAdd, j: start + j})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, listOp{kind: opRemove, i: start + i})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, listOp{kind: opAdd, j: start + j})
+	}
+
+	for k := 0; k < len(a)-endA; k++ {
+		ops = append(ops, listOp{kind: opEqual, i: endA + k, j: endB + k})
+	}
+
+	return ops
+}
+
+func indexKey(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// elementKey labels a list element by its index in the old and the new
+// list, -1 meaning it is not in that list: "[2]" when it kept its place,
+// "[2→1]" when it shifted, "[1→]" when it was removed and "[→3]" when it
+// was added. Unlike the index in either list alone, the label is unique
+// among the elements of one diff.
+func elementKey(i, j int) string {
+	switch {
+	case i == j:
+		return indexKey(i)
+	case i < 0:
+		return "[→" + strconv.Itoa(j) + "]"
+	case j < 0:
+		return "[" + strconv.Itoa(i) + "→]"
+	default:
+		return "[" + strconv.Itoa(i) + "→" + strconv.Itoa(j) + "]"
+	}
+}
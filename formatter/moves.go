@@ -0,0 +1,179 @@
+package formatter
+
+import (
+	"sort"
+	"strings"
+)
+
+type moveCandidate struct {
+	node  *DiffNode
+	sub   []string
+	path  string
+	value any
+}
+
+// detectMoves pairs removed and added values that are equal into "moved"
+// nodes placed at the destination, dropping the removed side. Values inside
+// added or removed objects take part too, so renaming "db_host" to
+// "database.host" is found even when "database" itself is new. A pair is
+// only made when the value is unique on both sides and says enough to be
+// recognised, see movable; anything else stays a plain removal and addition.
+func (d *differ) detectMoves(diff []*DiffNode) []*DiffNode {
+	var removed, added []moveCandidate
+	collectMoveCandidates(diff, "", false, &removed, &added)
+
+	var used []string
+	drop := make(map[*DiffNode]bool)
+
+	for _, to := range added {
+		from, ok := d.uniqueMatch(to, removed, added)
+		if !ok || overlapsAny(used, from.path) || overlapsAny(used, to.path) {
+			continue
+		}
+		used = append(used, from.path, to.path)
+
+		drop[locate(from.node, from.sub)] = true
+
+		dest := locate(to.node, to.sub)
+		*dest = *NewMoved(dest.Key, from.path, to.path, dest.Value)
+	}
+
+	if len(drop) == 0 {
+		return diff
+	}
+	return pruneNodes(diff, drop)
+}
+
+func (d *differ) uniqueMatch(to moveCandidate, removed, added []moveCandidate) (moveCandidate, bool) {
+	var match moveCandidate
+	count := 0
+	for _, from := range removed {
+		if d.equal(from.value, to.value) {
+			match = from
+			count++
+		}
+	}
+	if count != 1 {
+		return moveCandidate{}, false
+	}
+
+	for _, other := range added {
+		if other.path != to.path && d.equal(other.value, match.value) {
+			return moveCandidate{}, false
+		}
+	}
+	return match, true
+}
+
+func collectMoveCandidates(nodes []*DiffNode, path string, inList bool, removed, added *[]moveCandidate) {
+	if inList {
+		return
+	}
+
+	for _, node := range nodes {
+		p := joinPath(path, node.Key, false)
+		switch node.Type {
+		case Removed:
+			appendMoveCandidates(removed, node, nil, p, node.Value)
+		case Added:
+			appendMoveCandidates(added, node, nil, p, node.Value)
+		case Nested:
+			collectMoveCandidates(node.Children, p, node.Array, removed, added)
+		}
+	}
+}
+
+func appendMoveCandidates(dst *[]moveCandidate, node *DiffNode, sub []string, path string, value any) {
+	if movable(value) {
+		*dst = append(*dst, moveCandidate{node: node, sub: sub, path: path, value: value})
+	}
+
+	if m, ok := value.(map[string]any); ok {
+		for _, k := range sortedKeys(m) {
+			next := append(append([]string{}, sub...), k)
+			appendMoveCandidates(dst, node, next, joinPath(path, k, false), m[k])
+		}
+	}
+}
+
+// locate returns the node for the value at sub inside node, splitting an
+// added or removed object into a nested node of added or removed keys on the
+// way down.
+func locate(node *DiffNode, sub []string) *DiffNode {
+	for _, key := range sub {
+		if node.Type != Nested {
+			node.Children = expandObject(node.Type, node.Path, node.Value.(map[string]any))
+			node.Type = Nested
+			node.Value = nil
+		}
+		for _, child := range node.Children {
+			if child.Key == key {
+				node = child
+				break
+			}
+		}
+	}
+	return node
+}
+
+func expandObject(kind ChangeKind, path string, m map[string]any) []*DiffNode {
+	children := make([]*DiffNode, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		children = append(children, &DiffNode{Type: kind, Key: k, Path: joinPath(path, k, false), Value: m[k]})
+	}
+	return children
+}
+
+// pruneNodes removes the nodes in drop, such as the source side of detected
+// moves, together with nested nodes that have nothing left in them.
+func pruneNodes(nodes []*DiffNode, drop map[*DiffNode]bool) []*DiffNode {
+	result := make([]*DiffNode, 0, len(nodes))
+	for _, node := range nodes {
+		if drop[node] {
+			continue
+		}
+		if node.Type == Nested {
+			node.Children = pruneNodes(node.Children, drop)
+			if len(node.Children) == 0 {
+				continue
+			}
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// minMoveLength is the length a string needs to count as moved. Shorter
+// ones, like numbers and booleans, are equal by coincidence too often.
+const minMoveLength = 8
+
+// movable reports whether value can be recognised where it moved to: a
+// non-empty object or list, or a string of at least minMoveLength bytes.
+func movable(value any) bool {
+	switch v := value.(type) {
+	case map[string]any, []any:
+		return !isEmpty(v)
+	case string:
+		return len(v) >= minMoveLength
+	default:
+		return false
+	}
+}
+
+func overlapsAny(paths []string, p string) bool {
+	for _, q := range paths {
+		if p == q || strings.HasPrefix(p, q+".") || strings.HasPrefix(q, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
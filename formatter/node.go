@@ -0,0 +1,91 @@
+package formatter
+
+// DiffNode is one entry of the tree returned by BuildDiff. Path is the full
+// location of the value, e.g. "servers[3].port"; Key is its last segment,
+// except for list elements, whose Key names both their old and new index
+// (see elementKey) so that it is unique among its siblings.
+// Which of the value fields are set depends on Type: Value for Added,
+// Removed, Unchanged and Moved, OldVal and NewVal for Updated and
+// TypeChanged, Children for Nested. Affects lists the paths that inherited
+// this change from a YAML anchor, see WithAliases. OldPos and NewPos locate
+// the value in the old and new input when WithPositions is used. KeyOrder,
+// set when WithKeyOrder is used, lists the keys of the maps within the
+// values of the node by path, in the order they are shown.
+type DiffNode struct {
+	Type     ChangeKind          `json:"type"`
+	Key      string              `json:"key"`
+	Path     string              `json:"path,omitempty"`
+	Value    any                 `json:"value,omitempty"`
+	OldVal   any                 `json:"old_value,omitempty"`
+	NewVal   any                 `json:"new_value,omitempty"`
+	OldType  string              `json:"old_type,omitempty"`
+	NewType  string              `json:"new_type,omitempty"`
+	From     string              `json:"from,omitempty"`
+	To       string              `json:"to,omitempty"`
+	Array    bool                `json:"array,omitempty"`
+	Children []*DiffNode         `json:"children,omitempty"`
+	Affects  []string            `json:"affects,omitempty"`
+	OldPos   *Position           `json:"old_pos,omitempty"`
+	NewPos   *Position           `json:"new_pos,omitempty"`
+	KeyOrder map[string][]string `json:"-"`
+}
+
+// mapKeys lists the keys of m, the map at path, as order gives them, or
+// sorted when it does not know the path.
+func mapKeys(m map[string]any, path string, order map[string][]string) []string {
+	known, ok := order[path]
+	if !ok {
+		return sortedKeys(m)
+	}
+	keys := make([]string, 0, len(m))
+	for _, k := range known {
+		if _, ok := m[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func NewAdded(path, key string, value any) *DiffNode {
+	return &DiffNode{Type: Added, Key: key, Path: path, Value: value}
+}
+
+func NewRemoved(path, key string, value any) *DiffNode {
+	return &DiffNode{Type: Removed, Key: key, Path: path, Value: value}
+}
+
+func NewUnchanged(path, key string, value any) *DiffNode {
+	return &DiffNode{Type: Unchanged, Key: key, Path: path, Value: value}
+}
+
+func NewUpdated(path, key string, oldVal, newVal any) *DiffNode {
+	return &DiffNode{Type: Updated, Key: key, Path: path, OldVal: oldVal, NewVal: newVal}
+}
+
+func NewTypeChanged(path, key string, oldVal, newVal any) *DiffNode {
+	return &DiffNode{
+		Type:    TypeChanged,
+		Key:     key,
+		Path:    path,
+		OldVal:  oldVal,
+		NewVal:  newVal,
+		OldType: typeName(oldVal),
+		NewType: typeName(newVal),
+	}
+}
+
+// NewMoved describes a value that disappeared at from and appeared unchanged
+// at to; the node itself lives at the destination.
+func NewMoved(key, from, to string, value any) *DiffNode {
+	return &DiffNode{Type: Moved, Key: key, Path: to, Value: value, From: from, To: to}
+}
+
+func NewNested(path, key string, children []*DiffNode) *DiffNode {
+	return &DiffNode{Type: Nested, Key: key, Path: path, Children: children}
+}
+
+// NewNestedList is NewNested for lists: the children are list elements keyed
+// by "[index]", "[old→new]" or "[field=value]".
+func NewNestedList(path, key string, children []*DiffNode) *DiffNode {
+	return &DiffNode{Type: Nested, Key: key, Path: path, Array: true, Children: children}
+}
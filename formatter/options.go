@@ -0,0 +1,240 @@
+package formatter
+
+import (
+	"reflect"
+	"slices"
+)
+
+type Option func(*options)
+
+type options struct {
+	arrayKeys []arrayKey
+	unordered []string
+	strict    bool
+	ignore    []string
+	only      []string
+	moves     bool
+	aliases   map[string]string
+
+	oldPositions map[string]Position
+	newPositions map[string]Position
+
+	primaryOrder   map[string][]string
+	secondaryOrder map[string][]string
+}
+
+type arrayKey struct {
+	pattern string
+	field   string
+}
+
+// WithArrayKey matches the elements of lists whose path matches pattern by
+// the value of field instead of by position, e.g.
+// WithArrayKey("spec.containers", "name"). field may be a dotted path, or
+// several comma-separated fields that together identify an element, e.g.
+// WithArrayKey("documents", "apiVersion,kind,metadata.name"). Keys match
+// when their values are equal, so 1 and 1.0 do but 1 and "1" do not.
+// Elements without the field are matched by position among themselves, and
+// a list with a repeated key is compared by position.
+func WithArrayKey(pattern, field string) Option {
+	return func(o *options) {
+		o.arrayKeys = append(o.arrayKeys, arrayKey{pattern: pattern, field: field})
+	}
+}
+
+// WithUnorderedLists compares lists whose path matches one of patterns as
+// multisets: reordering their elements is not a change, and only elements
+// that were really added or removed are reported. Use "**" for all lists.
+func WithUnorderedLists(patterns ...string) Option {
+	return func(o *options) {
+		o.unordered = append(o.unordered, patterns...)
+	}
+}
+
+// WithStrictTypes makes values of different Go types unequal even when they
+// denote the same number or date, so an int becoming a float is reported.
+func WithStrictTypes() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithIgnore drops every path matching one of patterns from both inputs
+// before they are compared, e.g. "metadata.resourceVersion", "**.lastUpdated"
+// or "items[*].status". List elements are addressed as in the diff: by
+// their original position, or as "[field=value]" in lists keyed with
+// WithArrayKey.
+func WithIgnore(patterns ...string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, patterns...)
+	}
+}
+
+// WithOnly restricts the comparison to the subtrees matching one of
+// patterns, e.g. "database.*" or "services.api". Their ancestors are kept so
+// the output retains the surrounding nesting and full paths.
+func WithOnly(patterns ...string) Option {
+	return func(o *options) {
+		o.only = append(o.only, patterns...)
+	}
+}
+
+// WithMoveDetection reports a value that was removed in one place and added
+// unchanged in another as a single move, e.g. renaming "db_host" to
+// "database.host". Numbers, booleans and short strings are left out, since
+// they are often equal by coincidence.
+func WithMoveDetection() Option {
+	return func(o *options) {
+		o.moves = true
+	}
+}
+
+// WithAliases reports a change to a YAML anchor once instead of at every
+// place the anchor was copied to. aliases maps the path of each copied value
+// to the path of the anchored value it came from, as returned by
+// parser.YAMLAliases; a copy that changed exactly like its anchor is dropped
+// and its path listed in the anchor's Affects.
+func WithAliases(aliases map[string]string) Option {
+	return func(o *options) {
+		o.aliases = aliases
+	}
+}
+
+// WithPositions sets OldPos and NewPos on the diff nodes, taking them from
+// the positions of the values of each input keyed by path, as returned by
+// parser.Positions. Formatters then show where each change is, e.g.
+// "a.yaml:12 -> b.yaml:14".
+func WithPositions(oldPositions, newPositions map[string]Position) Option {
+	return func(o *options) {
+		o.oldPositions = oldPositions
+		o.newPositions = newPositions
+	}
+}
+
+// WithKeyOrder lists the keys of each map in document order instead of
+// sorted. Both arguments map the path of a map to its keys in the order of
+// one input, as returned by parser.KeyOrder: keys follow the primary order,
+// keys only the secondary input has come right after the key they follow
+// there, and keys neither knows about (maps inside keyed or reordered lists,
+// formats without positions) come last, sorted.
+func WithKeyOrder(primary, secondary map[string][]string) Option {
+	return func(o *options) {
+		o.primaryOrder = primary
+		o.secondaryOrder = secondary
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) arrayKeyFor(path string) (string, bool) {
+	for _, ak := range o.arrayKeys {
+		if matchPath(ak.pattern, path) {
+			return ak.field, true
+		}
+	}
+	return "", false
+}
+
+func (o options) isUnordered(path string) bool {
+	return matchAny(o.unordered, path)
+}
+
+func (o options) isIgnored(path string) bool {
+	return matchAny(o.ignore, path)
+}
+
+// orderKeys arranges the sorted keys of the map at path as WithKeyOrder
+// describes.
+func (o options) orderKeys(path string, keys []string) []string {
+	primary, secondary := o.primaryOrder[path], o.secondaryOrder[path]
+	if len(primary) == 0 && len(secondary) == 0 {
+		return keys
+	}
+
+	present := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		present[k] = true
+	}
+	placed := make(map[string]bool, len(keys))
+	ordered := make([]string, 0, len(keys))
+
+	for _, k := range primary {
+		if present[k] && !placed[k] {
+			ordered = append(ordered, k)
+			placed[k] = true
+		}
+	}
+
+	at := 0
+	for _, k := range secondary {
+		if !present[k] {
+			continue
+		}
+		if placed[k] {
+			at = slices.Index(ordered, k) + 1
+			continue
+		}
+		ordered = slices.Insert(ordered, at, k)
+		placed[k] = true
+		at++
+	}
+
+	for _, k := range keys {
+		if !placed[k] {
+			ordered = append(ordered, k)
+		}
+	}
+	return ordered
+}
+
+// attachKeyOrder sets KeyOrder on every node whose values hold maps, so
+// that formatters list their keys as orderKeys does for the diff itself.
+func (o options) attachKeyOrder(nodes []*DiffNode) {
+	for _, node := range nodes {
+		keys := make(map[string]map[string]bool)
+		for _, v := range []any{node.Value, node.OldVal, node.NewVal} {
+			collectMapKeys(v, node.Path, keys)
+		}
+		if len(keys) > 0 {
+			node.KeyOrder = make(map[string][]string, len(keys))
+			for path, set := range keys {
+				sorted := make([]string, 0, len(set))
+				for k := range set {
+					sorted = append(sorted, k)
+				}
+				slices.Sort(sorted)
+				node.KeyOrder[path] = o.orderKeys(path, sorted)
+			}
+		}
+		o.attachKeyOrder(node.Children)
+	}
+}
+
+// collectMapKeys adds the keys of v, the value at path, and of the maps
+// nested in it to keys, by path.
+func collectMapKeys(v any, path string, keys map[string]map[string]bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if keys[path] == nil {
+		keys[path] = make(map[string]bool, len(m))
+	}
+	for k, x := range m {
+		keys[path][k] = true
+		collectMapKeys(x, joinPath(path, k, false), keys)
+	}
+}
+
+func (o options) equal(a, b any) bool {
+	if o.strict {
+		return reflect.DeepEqual(a, b)
+	}
+	return looseEqual(a, b)
+}
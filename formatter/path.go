@@ -0,0 +1,131 @@
+package formatter
+
+import (
+	"path"
+	"strings"
+)
+
+func joinPath(parent, key string, inList bool) string {
+	switch {
+	case inList:
+		return parent + key
+	case parent == "":
+		return key
+	default:
+		return parent + "." + key
+	}
+}
+
+// matchPath reports whether a diff path such as "spec.containers[0].image"
+// matches pattern. Dots separate segments, "*" matches exactly one segment
+// (a key or an index), "**" matches any number of segments and "[*]" matches
+// any list element. Key segments may also use path.Match wildcards.
+func matchPath(pattern, p string) bool {
+	return matchSegments(splitPath(pattern), splitPath(p))
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyPrefix reports whether some path below p could match one of
+// patterns.
+func matchAnyPrefix(patterns []string, p string) bool {
+	segs := splitPath(p)
+	for _, pattern := range patterns {
+		if matchPrefixSegments(splitPath(pattern), segs) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPrefixSegments(pattern, segs []string) bool {
+	switch {
+	case len(segs) == 0:
+		return true
+	case len(pattern) == 0:
+		return false
+	case pattern[0] == "**":
+		return true
+	}
+	return matchSegment(pattern[0], segs[0]) && matchPrefixSegments(pattern[1:], segs[1:])
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segs) == 0 {
+		return false
+	}
+
+	return matchSegment(pattern[0], segs[0]) && matchSegments(pattern[1:], segs[1:])
+}
+
+func matchSegment(pattern, seg string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case isIndexSegment(pattern):
+		return pattern == seg || (pattern == "[*]" && isIndexSegment(seg))
+	case isIndexSegment(seg):
+		return false
+	}
+
+	ok, err := path.Match(pattern, seg)
+	return err == nil && ok
+}
+
+func splitPath(p string) []string {
+	var segs []string
+	var cur strings.Builder
+
+	flush := func() {
+		if cur.Len() > 0 {
+			segs = append(segs, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '.':
+			flush()
+		case '[':
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				cur.WriteString(p[i:])
+				i = len(p)
+				continue
+			}
+			flush()
+			segs = append(segs, p[i:i+end+1])
+			i += end
+		default:
+			cur.WriteByte(p[i])
+		}
+	}
+	flush()
+
+	return segs
+}
+
+func isIndexSegment(seg string) bool {
+	return strings.HasPrefix(seg, "[") && strings.HasSuffix(seg, "]")
+}
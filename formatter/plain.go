@@ -2,38 +2,63 @@ package formatter
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 func FormatPlain(nodes []*DiffNode, path string) string {
+	return formatPlain(nodes, path, false)
+}
+
+func formatPlain(nodes []*DiffNode, path string, inList bool) string {
 	var lines []string
 
 	for _, node := range nodes {
-		currentPath := node.Key
-		if path != "" {
-			currentPath = path + "." + node.Key
+		currentPath := joinPath(path, node.Key, inList)
+		if inList {
+			currentPath = elementPath(path, node)
 		}
 
 		switch node.Type {
-		case "added":
-			lines = append(lines, fmt.Sprintf("Property '%s' was added with value: %s",
-				currentPath, formatPlainValue(node.Value)))
-		case "removed":
-			lines = append(lines, fmt.Sprintf("Property '%s' was removed", currentPath))
-		case "updated":
-			lines = append(lines, fmt.Sprintf("Property '%s' was updated. From %s to %s",
-				currentPath, formatPlainValue(node.OldVal), formatPlainValue(node.NewVal)))
-		case "nested":
-			nested := FormatPlain(node.Children, currentPath)
+		case Added:
+			lines = append(lines, fmt.Sprintf("Property '%s' was added with value: %s%s%s",
+				currentPath, formatPlainValue(node.Value), affectsNote(node), positionNote(node)))
+		case Removed:
+			lines = append(lines, fmt.Sprintf("Property '%s' was removed%s%s", currentPath, affectsNote(node), positionNote(node)))
+		case Updated:
+			lines = append(lines, fmt.Sprintf("Property '%s' was updated. From %s to %s%s%s",
+				currentPath, formatPlainValue(node.OldVal), formatPlainValue(node.NewVal), affectsNote(node), positionNote(node)))
+		case TypeChanged:
+			lines = append(lines, fmt.Sprintf("Property '%s' changed type from %s to %s. From %s to %s%s%s",
+				currentPath, node.OldType, node.NewType, formatPlainValue(node.OldVal), formatPlainValue(node.NewVal),
+				affectsNote(node), positionNote(node)))
+		case Moved:
+			lines = append(lines, fmt.Sprintf("Property '%s' was moved to '%s'%s", node.From, node.To, positionNote(node)))
+		case Nested:
+			nested := formatPlain(node.Children, currentPath, node.Array)
 			if nested != "" {
 				lines = append(lines, nested)
 			}
+		case Unchanged:
+		default:
+			lines = append(lines, fmt.Sprintf("Property '%s' has unknown change %s", currentPath, node.Type))
 		}
 	}
 
 	return strings.Join(lines, "\n")
 }
 
+// elementPath is the path of a list element as plain output shows it: the
+// element's index in the file it is in, rather than the old→new label of
+// its key.
+func elementPath(parent string, node *DiffNode) string {
+	segs := splitPath(node.Path)
+	if len(segs) == 0 {
+		return parent + node.Key
+	}
+	return parent + segs[len(segs)-1]
+}
+
 func formatPlainValue(value any) string {
 	switch v := value.(type) {
 	case map[string]any:
@@ -44,6 +69,8 @@ func formatPlainValue(value any) string {
 		return fmt.Sprintf("'%s'", v)
 	case bool:
 		return fmt.Sprintf("%t", v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
 	case nil:
 		return "null"
 	default:
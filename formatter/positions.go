@@ -0,0 +1,89 @@
+package formatter
+
+import "fmt"
+
+// Position locates a value in one of the compared files.
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// recordIndexes remembers which elements of the old and new list a node
+// under a list stands for, -1 meaning none, so attachPositions can find them
+// even when the node is keyed by the other side's index or by a field.
+func (d *differ) recordIndexes(node *DiffNode, i, j int) *DiffNode {
+	if d.listIndexes != nil {
+		d.listIndexes[node] = [2]int{i, j}
+	}
+	return node
+}
+
+// attachPositions sets OldPos and NewPos on every node from the positions
+// given to WithPositions.
+func (d *differ) attachPositions(nodes []*DiffNode, oldParent, newParent string, inList bool) {
+	for _, node := range nodes {
+		oldPath := joinPath(oldParent, node.Key, false)
+		newPath := joinPath(newParent, node.Key, false)
+		if inList {
+			oldPath, newPath = "", ""
+			if idx, ok := d.listIndexes[node]; ok {
+				if idx[0] >= 0 {
+					oldPath = oldParent + indexKey(idx[0])
+				}
+				if idx[1] >= 0 {
+					newPath = newParent + indexKey(idx[1])
+				}
+			}
+		}
+
+		switch node.Type {
+		case Added:
+			node.NewPos = lookupPosition(d.newPositions, newPath)
+		case Removed:
+			node.OldPos = lookupPosition(d.oldPositions, oldPath)
+		case Moved:
+			node.OldPos = lookupPosition(d.oldPositions, node.From)
+			node.NewPos = lookupPosition(d.newPositions, node.To)
+		default:
+			node.OldPos = lookupPosition(d.oldPositions, oldPath)
+			node.NewPos = lookupPosition(d.newPositions, newPath)
+		}
+
+		d.attachPositions(node.Children, oldPath, newPath, node.Array)
+	}
+}
+
+// lookupPosition finds the position of p, or failing that of its closest
+// parent that has one, such as the alias a YAML value was copied through.
+func lookupPosition(positions map[string]Position, p string) *Position {
+	if p == "" {
+		return nil
+	}
+
+	segs := splitPath(p)
+	for i := len(segs); i > 0; i-- {
+		if pos, ok := positions[buildPath(segs[:i])]; ok {
+			return &pos
+		}
+	}
+	return nil
+}
+
+// positionNote is the " (old.yaml:12 -> new.yaml:14)" suffix formatters add
+// to a change whose source positions are known.
+func positionNote(node *DiffNode) string {
+	switch {
+	case node.OldPos != nil && node.NewPos != nil:
+		return fmt.Sprintf(" (%s -> %s)", node.OldPos, node.NewPos)
+	case node.OldPos != nil:
+		return fmt.Sprintf(" (%s)", node.OldPos)
+	case node.NewPos != nil:
+		return fmt.Sprintf(" (%s)", node.NewPos)
+	}
+	return ""
+}
@@ -2,7 +2,7 @@ package formatter
 
 import (
     "fmt"
-    "sort"
+    "strconv"
     "strings"
 )
 
@@ -14,14 +14,7 @@ func FormatStylish(nodes []*DiffNode, depth int) string {
     }
 
     lines := []string{"{"}
-
-    sortedNodes := make([]*DiffNode, len(nodes))
-    copy(sortedNodes, nodes)
-    sort.Slice(sortedNodes, func(i, j int) bool {
-        return sortedNodes[i].Key < sortedNodes[j].Key
-    })
-
-    for _, node := range sortedNodes {
+    for _, node := range nodes {
         lines = append(lines, formatNode(node, depth))
     }
 
@@ -33,33 +26,74 @@ func FormatStylish(nodes []*DiffNode, depth int) string {
 func formatNode(node *DiffNode, depth int) string {
     propIndent := strings.Repeat(" ", (depth+1)*indentSize)
     markerIndent := strings.Repeat(" ", (depth+1)*indentSize-2)
+    value := func(v any) string {
+        return formatValue(v, depth+1, node.Path, node.KeyOrder)
+    }
 
     switch node.Type {
-    case "added":
-        return fmt.Sprintf("%s+ %s: %s", markerIndent, node.Key, FormatValue(node.Value, depth+1))
-    case "removed":
-        return fmt.Sprintf("%s- %s: %s", markerIndent, node.Key, FormatValue(node.Value, depth+1))
-    case "unchanged":
-        return fmt.Sprintf("%s%s: %s", propIndent, node.Key, FormatValue(node.Value, depth+1))
-    case "updated":
-        line1 := fmt.Sprintf("%s- %s: %s", markerIndent, node.Key, FormatValue(node.OldVal, depth+1))
-        line2 := fmt.Sprintf("%s+ %s: %s", markerIndent, node.Key, FormatValue(node.NewVal, depth+1))
+    case Added:
+        return fmt.Sprintf("%s+ %s: %s%s%s", markerIndent, node.Key, value(node.Value),
+            affectsNote(node), positionNote(node))
+    case Removed:
+        return fmt.Sprintf("%s- %s: %s%s%s", markerIndent, node.Key, value(node.Value),
+            affectsNote(node), positionNote(node))
+    case Unchanged:
+        return fmt.Sprintf("%s%s: %s", propIndent, node.Key, value(node.Value))
+    case Updated:
+        line1 := fmt.Sprintf("%s- %s: %s", markerIndent, node.Key, value(node.OldVal))
+        line2 := fmt.Sprintf("%s+ %s: %s%s%s", markerIndent, node.Key, value(node.NewVal),
+            affectsNote(node), positionNote(node))
         return line1 + "\n" + line2
-    case "nested":
+    case TypeChanged:
+        line1 := fmt.Sprintf("%s- %s: %s", markerIndent, node.Key, value(node.OldVal))
+        line2 := fmt.Sprintf("%s+ %s: %s (type changed from %s to %s)%s%s",
+            markerIndent, node.Key, value(node.NewVal), node.OldType, node.NewType,
+            affectsNote(node), positionNote(node))
+        return line1 + "\n" + line2
+    case Moved:
+        return fmt.Sprintf("%s+ %s: %s (moved from %s)%s", markerIndent, node.Key, value(node.Value),
+            node.From, positionNote(node))
+    case Nested:
+        if node.Array {
+            return fmt.Sprintf("%s%s: %s", propIndent, node.Key, formatStylishList(node.Children, depth+1))
+        }
         nestedBlock := FormatStylish(node.Children, depth+1)
         return fmt.Sprintf("%s%s: %s", propIndent, node.Key, nestedBlock)
     }
-    return ""
+    return fmt.Sprintf("%s? %s: %s", markerIndent, node.Key, node.Type)
+}
+
+func formatStylishList(nodes []*DiffNode, depth int) string {
+    if len(nodes) == 0 {
+        return "[]"
+    }
+
+    lines := []string{"["}
+    for _, node := range nodes {
+        lines = append(lines, formatNode(node, depth))
+    }
+
+    closingIndent := strings.Repeat(" ", depth*indentSize)
+    lines = append(lines, fmt.Sprintf("%s]", closingIndent))
+    return strings.Join(lines, "\n")
 }
 
 func FormatValue(value any, depth int) string {
+    return formatValue(value, depth, "", nil)
+}
+
+// formatValue is FormatValue for the value at path, listing the keys of maps
+// as order gives them.
+func formatValue(value any, depth int, path string, order map[string][]string) string {
     switch v := value.(type) {
     case map[string]any:
-        return formatMap(v, depth)
+        return formatMap(v, depth, path, order)
     case string:
         return v
     case bool:
         return fmt.Sprintf("%t", v)
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64)
     case nil:
         return "null"
     default:
@@ -67,22 +101,17 @@ func FormatValue(value any, depth int) string {
     }
 }
 
-func formatMap(m map[string]any, depth int) string {
+func formatMap(m map[string]any, depth int, path string, order map[string][]string) string {
     if len(m) == 0 {
         return "{}"
     }
 
-    keys := make([]string, 0, len(m))
-    for k := range m {
-        keys = append(keys, k)
-    }
-    sort.Strings(keys)
-
+    keys := mapKeys(m, path, order)
     lines := []string{"{"}
     propIndent := strings.Repeat(" ", (depth+1)*indentSize)
 
     for _, k := range keys {
-        valStr := FormatValue(m[k], depth+1)
+        valStr := formatValue(m[k], depth+1, joinPath(path, k, false), order)
         lines = append(lines, fmt.Sprintf("%s%s: %s", propIndent, k, valStr))
     }
 
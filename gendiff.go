@@ -1,35 +1,455 @@
 package code
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	parser "code/parser"
 	formatter "code/formatter"
 )
 
 
-func GenDiff(path1, path2, format string) (string, error) {
-    data1, err := parser.ParseFile(path1)
+// StdinPath is the file name GenDiff reads from standard input.
+const StdinPath = "-"
+
+
+type Option func(*config)
+
+
+type config struct {
+    diffOptions []formatter.Option
+    inputFormat string
+    verbose     io.Writer
+    anchors     bool
+    positions   bool
+    keyOrder    string
+    strict      bool
+    warnings    io.Writer
+    decoders    map[string]parser.Decoder
+}
+
+
+func WithDiffOptions(opts ...formatter.Option) Option {
+    return func(c *config) {
+        c.diffOptions = append(c.diffOptions, opts...)
+    }
+}
+
+
+// WithInputFormat parses both inputs as format instead of guessing it from
+// the file extension or the content. It is the way to read a file whose
+// extension does not match its content, since a known extension is never
+// second-guessed, see parser.FileFormat.
+func WithInputFormat(format string) Option {
+    return func(c *config) {
+        c.inputFormat = format
+    }
+}
+
+
+// WithVerbose reports to w which format each input was parsed as.
+func WithVerbose(w io.Writer) Option {
+    return func(c *config) {
+        c.verbose = w
+    }
+}
+
+
+// WithAnchorReport reports a change to a YAML anchor once, noting how many
+// keys inherited it, instead of repeating it at every alias and merge key.
+func WithAnchorReport() Option {
+    return func(c *config) {
+        c.anchors = true
+    }
+}
+
+
+// WithPositions notes next to each change where it is in the two files,
+// e.g. "a.yaml:12 -> b.yaml:14". Positions are known for JSON, YAML and
+// TOML inputs.
+func WithPositions() Option {
+    return func(c *config) {
+        c.positions = true
+    }
+}
+
+
+// Key orders accepted by WithKeyOrder.
+const (
+    KeyOrderSorted = "sorted"
+    KeyOrderFirst  = "first"
+    KeyOrderSecond = "second"
+)
+
+
+// WithKeyOrder sets the order keys are listed in: KeyOrderSorted (the
+// default) sorts them, KeyOrderFirst and KeyOrderSecond follow the order of
+// the first or second file, merging in the keys only the other one has.
+// Document order is known for JSON, YAML and TOML inputs.
+func WithKeyOrder(order string) Option {
+    return func(c *config) {
+        c.keyOrder = order
+    }
+}
+
+
+// WithStrict fails on ambiguities the parsers would otherwise resolve
+// silently: duplicate keys, non-string keys and NaN or infinite numbers.
+// The error wraps a *parser.StrictError for each of them.
+func WithStrict() Option {
+    return func(c *config) {
+        c.strict = true
+    }
+}
+
+
+// WithStrictWarnings reports to w the ambiguities WithStrict fails on, one
+// per line with its position, and compares the files anyway.
+func WithStrictWarnings(w io.Writer) Option {
+    return func(c *config) {
+        c.warnings = w
+    }
+}
+
+
+// WithDecoder reads inputs of format with dec instead of the decoder
+// registered for it, e.g. WithDecoder("ini", parser.INIDecoder{CoerceTypes:
+// true}), without changing what other callers of the parser get.
+func WithDecoder(format string, dec parser.Decoder) Option {
+    return func(c *config) {
+        if name, ok := parser.FormatName(format); ok {
+            format = name
+        }
+        if c.decoders == nil {
+            c.decoders = make(map[string]parser.Decoder)
+        }
+        c.decoders[format] = dec
+    }
+}
+
+
+func (c config) logf(format string, args ...any) {
+    if c.verbose != nil {
+        fmt.Fprintf(c.verbose, format+"\n", args...)
+    }
+}
+
+
+func GenDiff(path1, path2, format string, opts ...Option) (string, error) {
+    cfg := newConfig(opts)
+
+    if path1 == StdinPath && path2 == StdinPath {
+        return "", errors.New("standard input can be used for only one of the files")
+    }
+    if err := cfg.validate(); err != nil {
+        return "", err
+    }
+
+    in1, err := load(path1, cfg)
     if err != nil {
         return "", err
     }
 
-    data2, err := parser.ParseFile(path2)
+    in2, err := load(path2, cfg)
     if err != nil {
         return "", err
     }
 
-    diff := formatter.BuildDiff(data1, data2)
+    return render(in1, in2, format, cfg)
+}
+
+
+// GenDiffReaders compares the content of r1 and r2. Both are parsed as the
+// format given WithInputFormat, or as the one detected from each content.
+func GenDiffReaders(r1, r2 io.Reader, format string, opts ...Option) (string, error) {
+    cfg := newConfig(opts)
+    if err := cfg.validate(); err != nil {
+        return "", err
+    }
+
+    in1, err := loadReader(r1, "first input", cfg)
+    if err != nil {
+        return "", err
+    }
+
+    in2, err := loadReader(r2, "second input", cfg)
+    if err != nil {
+        return "", err
+    }
+
+    return render(in1, in2, format, cfg)
+}
+
+
+func newConfig(opts []Option) config {
+    var cfg config
+    for _, opt := range opts {
+        opt(&cfg)
+    }
+    return cfg
+}
+
+
+func (c config) validate() error {
+    switch c.keyOrder {
+    case "", KeyOrderSorted, KeyOrderFirst, KeyOrderSecond:
+        return nil
+    }
+    return fmt.Errorf("unsupported key order: %s (expected %s, %s, %s)",
+        c.keyOrder, KeyOrderSorted, KeyOrderFirst, KeyOrderSecond)
+}
 
-    switch format {
-    case "stylish":
-        return formatter.FormatStylish(diff, 0), nil
-    case "plain":
-        return formatter.FormatPlain(diff, ""), nil
-    case "json":
-        return formatter.FormatJSON(diff)
+
+// documentOrder reports whether keys are listed in the order of the files.
+func (c config) documentOrder() bool {
+    return c.keyOrder == KeyOrderFirst || c.keyOrder == KeyOrderSecond
+}
+
+
+// input is a parsed file together with what the options need to know about
+// its source.
+type input struct {
+    data      map[string]any
+    documents int
+    aliases   map[string]string
+    positions map[string]formatter.Position
+    keyOrder  map[string][]string
+}
+
+
+func load(path string, cfg config) (input, error) {
+    if path == StdinPath {
+        return loadReader(os.Stdin, "standard input", cfg)
+    }
+
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return input{}, fmt.Errorf("failed to read file '%s': %w", path, err)
+    }
+
+    format := cfg.inputFormat
+    if format == "" {
+        if format, err = parser.FileFormat(path, raw); err != nil {
+            return input{}, err
+        }
+    }
+    return cfg.decode(raw, format, path)
+}
+
+
+func loadReader(r io.Reader, name string, cfg config) (input, error) {
+    raw, err := io.ReadAll(r)
+    if err != nil {
+        return input{}, fmt.Errorf("failed to read %s: %w", name, err)
+    }
+
+    format := cfg.inputFormat
+    if format == "" {
+        if format, err = parser.DetectFormat(raw); err != nil {
+            return input{}, fmt.Errorf("%s: %w", name, err)
+        }
+    }
+    return cfg.decode(raw, format, name)
+}
+
+
+// decode parses raw, the whole content of the input called name, as format.
+func (c config) decode(raw []byte, format, name string) (input, error) {
+    if canonical, ok := parser.FormatName(format); ok {
+        format = canonical
+    }
+    c.logf("%s: parsed as %s", name, format)
+    if err := c.check(raw, format, name); err != nil {
+        return input{}, err
+    }
+
+    docs, err := c.parseDocuments(raw, format)
+    if err != nil {
+        return input{}, err
+    }
+
+    in := input{documents: len(docs)}
+    switch len(docs) {
+    case 0:
+    case 1:
+        in.data = docs[0]
     default:
-        return "", fmt.Errorf("unsupported format: %s", format)
+        list := make([]any, len(docs))
+        for i, doc := range docs {
+            list[i] = doc
+        }
+        in.data = map[string]any{parser.DocumentsKey: list}
     }
+    err = c.inspect(&in, raw, format, name)
+    return in, err
 }
 
+
+// parseDocuments parses raw as format, with the decoder WithDecoder set for
+// it if there is one. With WithStrictWarnings a repeated YAML key, already
+// reported by check, takes its last value instead of failing.
+func (c config) parseDocuments(raw []byte, format string) ([]map[string]any, error) {
+    if dec, ok := c.decoders[format]; ok {
+        return parser.DecodeDocuments(dec, raw)
+    }
+    if format == "yaml" && c.warnings != nil && !c.strict {
+        return parser.DecodeDocuments(parser.YAMLDecoder{LastKeyWins: true}, raw)
+    }
+    return parser.ParseDocuments(bytes.NewReader(raw), format)
+}
+
+
+// alignDocuments turns a single-document input into a list of one document
+// when the other input has several, so that documents are compared with
+// documents rather than a whole file with the list of them.
+func alignDocuments(in1, in2 *input) {
+    switch {
+    case in1.documents > 1 && in2.documents <= 1:
+        in2.asDocuments()
+    case in2.documents > 1 && in1.documents <= 1:
+        in1.asDocuments()
+    }
+}
+
+
+// asDocuments puts the data of in, and the paths describing it, under
+// documents[0].
+func (in *input) asDocuments() {
+    docs := []any{}
+    if in.documents == 1 {
+        docs = append(docs, in.data)
+    }
+    in.data = map[string]any{parser.DocumentsKey: docs}
+
+    prefix := func(path string) string {
+        if path == "" {
+            return parser.DocumentsKey + "[0]"
+        }
+        return parser.DocumentsKey + "[0]." + path
+    }
+    if in.aliases != nil {
+        aliases := make(map[string]string, len(in.aliases))
+        for path, src := range in.aliases {
+            aliases[prefix(path)] = prefix(src)
+        }
+        in.aliases = aliases
+    }
+    if in.positions != nil {
+        positions := make(map[string]formatter.Position, len(in.positions))
+        for path, pos := range in.positions {
+            positions[prefix(path)] = pos
+        }
+        in.positions = positions
+    }
+    if in.keyOrder != nil {
+        keyOrder := map[string][]string{"": {parser.DocumentsKey}}
+        for path, keys := range in.keyOrder {
+            keyOrder[prefix(path)] = keys
+        }
+        in.keyOrder = keyOrder
+    }
+}
+
+
+// inspect fills in the aliases and source positions of in that the enabled
+// options ask for.
+func (c config) inspect(in *input, raw []byte, format, name string) error {
+    var err error
+    if c.anchors {
+        if in.aliases, err = parser.Aliases(raw, format); err != nil {
+            return err
+        }
+    }
+
+    if c.positions {
+        positions, err := parser.Positions(raw, format)
+        if err != nil {
+            return err
+        }
+        in.positions = make(map[string]formatter.Position, len(positions))
+        for path, pos := range positions {
+            in.positions[path] = formatter.Position{File: name, Line: pos.Line, Column: pos.Column}
+        }
+    }
+
+    if c.documentOrder() {
+        if in.keyOrder, err = parser.KeyOrder(raw, format); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+
+// check fails on, or warns about, the ambiguities in raw when strict
+// parsing is enabled. It runs before raw is parsed, since some decoders
+// reject an ambiguity with an error of their own.
+func (c config) check(raw []byte, format, name string) error {
+    if !c.strict && c.warnings == nil {
+        return nil
+    }
+
+    var issues []*parser.StrictError
+    var err error
+    if dec, ok := c.decoders[format]; ok {
+        issues, err = parser.CheckDecoder(dec, raw)
+        if errors.Is(err, parser.ErrStrictUnsupported) {
+            err = fmt.Errorf("%w for %s input", err, format)
+        }
+    } else {
+        issues, err = parser.Check(raw, format)
+    }
+    if err != nil {
+        return fmt.Errorf("%s: %w", name, err)
+    }
+
+    if c.strict {
+        errs := make([]error, len(issues))
+        for i, issue := range issues {
+            errs[i] = fmt.Errorf("%s: %w", name, issue)
+        }
+        return errors.Join(errs...)
+    }
+    for _, issue := range issues {
+        fmt.Fprintf(c.warnings, "%s: %s\n", name, issue)
+    }
+    return nil
+}
+
+
+// commonAliases returns the aliases both inputs agree on, so a value that is
+// an alias in only one of the files is still reported where it changed.
+func commonAliases(aliases1, aliases2 map[string]string) map[string]string {
+    common := make(map[string]string)
+    for path, src := range aliases2 {
+        if aliases1[path] == src {
+            common[path] = src
+        }
+    }
+    return common
+}
+
+
+func render(in1, in2 input, format string, cfg config) (string, error) {
+    alignDocuments(&in1, &in2)
+
+    opts := cfg.diffOptions
+    if cfg.anchors {
+        opts = append(opts[:len(opts):len(opts)], formatter.WithAliases(commonAliases(in1.aliases, in2.aliases)))
+    }
+    if cfg.positions {
+        opts = append(opts[:len(opts):len(opts)], formatter.WithPositions(in1.positions, in2.positions))
+    }
+    switch cfg.keyOrder {
+    case KeyOrderFirst:
+        opts = append(opts[:len(opts):len(opts)], formatter.WithKeyOrder(in1.keyOrder, in2.keyOrder))
+    case KeyOrderSecond:
+        opts = append(opts[:len(opts):len(opts)], formatter.WithKeyOrder(in2.keyOrder, in1.keyOrder))
+    }
+
+    diff := formatter.BuildDiff(in1.data, in2.data, opts...)
+    return formatter.Format(diff, format)
+}
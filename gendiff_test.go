@@ -0,0 +1,149 @@
+package code
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestGenDiff_StrictDuplicateYAMLKeys(t *testing.T) {
+	first := writeFile(t, "a.yaml", "a: 1\nb: 2\na: 3\n")
+	second := writeFile(t, "b.yaml", "a: 3\nb: 4\n")
+
+	_, err := GenDiff(first, second, "plain", WithStrict())
+	require.Error(t, err)
+	var strictErr *parser.StrictError
+	require.True(t, errors.As(err, &strictErr))
+	assert.Equal(t, parser.DuplicateKey, strictErr.Kind)
+	assert.Equal(t, "a", strictErr.Path)
+	assert.Equal(t, parser.Position{Line: 3, Column: 1}, strictErr.Position)
+
+	var warnings bytes.Buffer
+	out, err := GenDiff(first, second, "plain", WithStrictWarnings(&warnings))
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'b' was updated. From 2 to 4", out)
+	assert.Equal(t, first+": line 3, column 1: duplicate key \"a\"\n", warnings.String())
+
+	_, err = GenDiff(first, second, "plain")
+	require.ErrorContains(t, err, `mapping key "a" already defined`)
+}
+
+func TestGenDiffReaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		opts   []Option
+		want   string
+	}{
+		{
+			name:   "detected formats",
+			first:  `{"host": "a", "port": 80}`,
+			second: "host: b\nport: 80\n",
+			want:   "Property 'host' was updated. From 'a' to 'b'",
+		},
+		{
+			name:   "explicit format",
+			first:  "host = a\n",
+			second: "host = b\n",
+			opts:   []Option{WithInputFormat("properties")},
+			want:   "Property 'host' was updated. From 'a' to 'b'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := GenDiffReaders(strings.NewReader(tt.first), strings.NewReader(tt.second), "plain", tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+		})
+	}
+
+	_, err := GenDiffReaders(strings.NewReader("{"), strings.NewReader("{}"), "plain", WithInputFormat("json"))
+	require.ErrorContains(t, err, "invalid JSON")
+}
+
+func TestGenDiff_YAMLDocuments(t *testing.T) {
+	single := writeFile(t, "single.yaml", "name: web\nport: 80\n")
+	multi := writeFile(t, "multi.yaml", "name: web\nport: 8080\n---\nname: db\nport: 5432\n")
+
+	out, err := GenDiff(single, multi, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'documents[0].port' was updated. From 80 to 8080\nProperty 'documents[1]' was added with value: [complex value]", out)
+
+	out, err = GenDiff(multi, single, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'documents[0].port' was updated. From 8080 to 80\nProperty 'documents[1]' was removed", out)
+
+	documents := writeFile(t, "documents.yaml", "documents: [web, db]\n")
+	out, err = GenDiff(documents, multi, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'documents[0].documents' was removed\nProperty 'documents[0].name' was added with value: 'web'\n"+
+		"Property 'documents[0].port' was added with value: 8080\nProperty 'documents[1]' was added with value: [complex value]", out)
+}
+
+func TestGenDiff_WithDecoder(t *testing.T) {
+	first := writeFile(t, "a.ini", "port = 80\ndebug = true\n")
+	second := writeFile(t, "b.ini", "port = 8080\ndebug = true\n")
+
+	out, err := GenDiff(first, second, "plain", WithDecoder("ini", parser.INIDecoder{CoerceTypes: true}))
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'port' was updated. From 80 to 8080", out)
+
+	out, err = GenDiff(first, second, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'port' was updated. From '80' to '8080'", out)
+}
+
+func TestGenDiff_DotenvNesting(t *testing.T) {
+	first := writeFile(t, "a.env", "DB__HOST=localhost\nDB__PORT=5432\n")
+	second := writeFile(t, "b.env", "DB__HOST=db\nDB__PORT=5432\n")
+
+	out, err := GenDiff(first, second, "plain", WithDecoder("dotenv", parser.DotenvDecoder{NestSeparator: "__"}))
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'DB.HOST' was updated. From 'localhost' to 'db'", out)
+
+	out, err = GenDiff(first, second, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'DB__HOST' was updated. From 'localhost' to 'db'", out)
+}
+
+func TestGenDiff_PropertiesNesting(t *testing.T) {
+	first := writeFile(t, "a.properties", "db.host=localhost\n")
+	second := writeFile(t, "b.properties", "db.host=db\n")
+
+	out, err := GenDiff(first, second, "stylish", WithDecoder("properties", parser.PropertiesDecoder{ExpandKeys: true}))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n    db: {\n      - host: localhost\n      + host: db\n    }\n}", out)
+
+	out, err = GenDiff(first, second, "stylish")
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  - db.host: localhost\n  + db.host: db\n}", out)
+}
+
+func TestGenDiff_ExtensionIsAuthoritative(t *testing.T) {
+	first := writeFile(t, "a.json", "name: web\nport: 80\n")
+	second := writeFile(t, "b.yaml", "name: web\nport: 8080\n")
+
+	_, err := GenDiff(first, second, "plain")
+	assert.ErrorContains(t, err, "invalid JSON")
+
+	out, err := GenDiff(first, second, "plain", WithInputFormat("yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "Property 'port' was updated. From 80 to 8080", out)
+}
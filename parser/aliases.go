@@ -0,0 +1,192 @@
+package parser
+
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+
+// YAMLAliases reports which values of a YAML file were copied from an
+// anchor. Each key is the path of a value that came from an alias (*name)
+// or a merge key (<<: *name), and maps to the path of the anchored value it
+// is a copy of, e.g. "services.web.image" -> "x-defaults.image". Paths use
+// the same syntax as diff paths, and documents of a multi-document file are
+// addressed as documents[i], as in the result of the YAML decoder.
+func YAMLAliases(data []byte) (map[string]string, error) {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w := aliasWalker{anchors: make(map[*yaml.Node]string), aliases: make(map[string]string)}
+	for i, doc := range docs {
+		w.walk(doc, documentPath(docs, i))
+	}
+	return w.aliases, nil
+}
+
+
+// Aliases is YAMLAliases for data parsed as format. Other formats have no
+// anchors, so they give no aliases.
+func Aliases(data []byte, format string) (map[string]string, error) {
+	if f, ok := lookupFormat(format); !ok || f.name != "yaml" {
+		return nil, nil
+	}
+	return YAMLAliases(data)
+}
+
+
+type aliasWalker struct {
+	anchors map[*yaml.Node]string
+	aliases map[string]string
+}
+
+
+func (w *aliasWalker) walk(node *yaml.Node, path string) {
+	if node.Anchor != "" {
+		w.anchors[node] = path
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		if src, ok := w.anchors[node.Alias]; ok {
+			w.aliases[path] = src
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			w.walk(item, indexPath(path, i))
+		}
+
+	case yaml.MappingNode:
+		seen := make(map[string]bool)
+		var merge *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isMergeKey(key) {
+				merge = value
+				continue
+			}
+			seen[key.Value] = true
+			w.walk(value, keyPath(path, key.Value))
+		}
+		if merge != nil {
+			w.merge(merge, path, seen)
+		}
+	}
+}
+
+
+// merge records the keys a mapping at path takes from a merge key. As in
+// the YAML decoder, keys set explicitly win over merged ones, and among
+// several merged mappings the first one to set a key wins.
+func (w *aliasWalker) merge(merge *yaml.Node, path string, seen map[string]bool) {
+	sources := []*yaml.Node{merge}
+	if merge.Kind == yaml.SequenceNode {
+		sources = merge.Content
+	}
+
+	for _, src := range sources {
+		if src.Kind != yaml.AliasNode {
+			continue
+		}
+		srcPath, ok := w.anchors[src.Alias]
+		if !ok {
+			continue
+		}
+		for _, key := range mappingKeys(src.Alias) {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			w.aliases[keyPath(path, key)] = keyPath(srcPath, key)
+		}
+	}
+}
+
+
+// mappingKeys lists the keys of a mapping node, including those it merges
+// in itself.
+func mappingKeys(node *yaml.Node) []string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var keys []string
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if isMergeKey(node.Content[i]) {
+			merge := node.Content[i+1]
+			merged = []*yaml.Node{merge}
+			if merge.Kind == yaml.SequenceNode {
+				merged = merge.Content
+			}
+			continue
+		}
+		keys = append(keys, node.Content[i].Value)
+	}
+	for _, m := range merged {
+		keys = append(keys, mappingKeys(m)...)
+	}
+	return keys
+}
+
+
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!merge"
+}
+
+
+// yamlDocuments returns the root node of each non-empty document in data,
+// skipping the same documents as the YAML decoder.
+func yamlDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: document %d: %w", n, err)
+		}
+		if len(doc.Content) > 0 && doc.Content[0].ShortTag() != "!!null" {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+
+// documentPath is the path of the i-th of docs in the decoded result: the
+// root for a single document, documents[i] for several.
+func documentPath(docs []*yaml.Node, i int) string {
+	if len(docs) == 1 {
+		return ""
+	}
+	return indexPath(DocumentsKey, i)
+}
+
+
+// keyPath and indexPath build paths in the syntax of diff paths, e.g.
+// "servers[0].port".
+func keyPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+
+func indexPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
@@ -0,0 +1,31 @@
+package parser
+
+
+import (
+	"errors"
+	"strings"
+)
+
+
+// DetectFormat guesses the format of a document from its content by trying
+// the registered formats in order: JSON first since it is the strictest, then
+// TOML, whose "key = value" lines YAML would take for plain strings, and
+// YAML, which accepts almost anything, last. A decoder implementing Detector
+// decides for itself; any other wins when it decodes the data. Decoders only
+// produce mappings, so a line of prose is not taken for a YAML scalar.
+func DetectFormat(data []byte) (string, error) {
+	for _, f := range snapshot() {
+		if detector, ok := f.decoder.(Detector); ok {
+			if detector.Detect(data) {
+				return f.name, nil
+			}
+			continue
+		}
+
+		if _, err := f.decoder.Decode(data); err == nil {
+			return f.name, nil
+		}
+	}
+
+	return "", errors.New("content is not a " + strings.Join(Formats(), ", ") + " document")
+}
@@ -0,0 +1,206 @@
+package parser
+
+
+import (
+	"fmt"
+	"strings"
+)
+
+
+// DotenvDecoder reads .env files: KEY=value lines with an optional
+// "export " prefix and '#' comments. Single-quoted values are taken
+// literally, double-quoted ones may span lines and understand \n, \t, \",
+// \\ and \$ escapes, and unquoted values end at " #". Values stay strings.
+type DotenvDecoder struct {
+	// NestSeparator, when set (usually "__"), splits keys into nested maps:
+	// DB__HOST=x becomes {"DB": {"HOST": "x"}}.
+	NestSeparator string
+}
+
+
+func (d DotenvDecoder) Decode(data []byte) (map[string]any, error) {
+	return d.decode(data, nil)
+}
+
+
+// decode parses data, calling onKey, if set, with each key and the line it
+// is on before the key is stored.
+func (d DotenvDecoder) decode(data []byte, onKey func(key string, line int)) (map[string]any, error) {
+	result := make(map[string]any)
+	src := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	line := 1
+	for len(src) > 0 {
+		var raw string
+		raw, src = cutLine(src)
+		text := strings.TrimSpace(raw)
+
+		if text == "" || text[0] == '#' {
+			line++
+			continue
+		}
+
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+		key, rest, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid dotenv: line %d: expected KEY=value, got %q", line, raw)
+		}
+
+		if onKey != nil {
+			onKey(key, line)
+		}
+		value, remaining, consumed, err := dotenvValue(strings.TrimLeft(rest, " \t"), src)
+		if err == nil {
+			err = d.set(result, key, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid dotenv: line %d: %w", line, err)
+		}
+		src = remaining
+		line += 1 + consumed
+	}
+
+	return result, nil
+}
+
+
+// Detect accepts content whose every meaningful line is an assignment to an
+// identifier-like key, optionally exported.
+func (DotenvDecoder) Detect(data []byte) bool {
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, _, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || !isEnvName(strings.TrimSpace(key)) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+
+func (d DotenvDecoder) set(result map[string]any, key string, value string) error {
+	if d.NestSeparator == "" {
+		result[key] = value
+		return nil
+	}
+
+	parts := strings.Split(key, d.NestSeparator)
+	node := result
+	for i, part := range parts[:len(parts)-1] {
+		child, exists := node[part]
+		if !exists {
+			next := make(map[string]any)
+			node[part] = next
+			node = next
+			continue
+		}
+		next, ok := child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("key %s conflicts with %s", key, strings.Join(parts[:i+1], d.NestSeparator))
+		}
+		node = next
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := node[last].(map[string]any); ok {
+		return fmt.Errorf("key %s conflicts with nested keys under it", key)
+	}
+	node[last] = value
+	return nil
+}
+
+
+// dotenvValue parses the value that starts at rest. A double-quoted value
+// may continue on the following lines, taken from src; it returns what is
+// left of src and how many extra lines were consumed.
+func dotenvValue(rest, src string) (string, string, int, error) {
+	if rest == "" {
+		return "", src, 0, nil
+	}
+
+	switch rest[0] {
+	case '\'':
+		end := strings.IndexByte(rest[1:], '\'')
+		if end < 0 {
+			return "", src, 0, fmt.Errorf("unterminated single-quoted value")
+		}
+		return rest[1 : end+1], src, 0, nil
+
+	case '"':
+		var b strings.Builder
+		consumed := 0
+		text := rest[1:]
+		for {
+			for i := 0; i < len(text); i++ {
+				switch c := text[i]; {
+				case c == '"':
+					return b.String(), src, consumed, nil
+				case c == '\\' && i+1 < len(text):
+					i++
+					b.WriteString(unescapeDotenv(text[i]))
+				default:
+					b.WriteByte(c)
+				}
+			}
+			if src == "" {
+				return "", src, consumed, fmt.Errorf("unterminated double-quoted value")
+			}
+			b.WriteByte('\n')
+			text, src = cutLine(src)
+			consumed++
+		}
+
+	default:
+		if i := strings.Index(rest, " #"); i >= 0 {
+			rest = rest[:i]
+		}
+		return strings.TrimSpace(rest), src, 0, nil
+	}
+}
+
+
+func unescapeDotenv(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$', '\'':
+		return string(c)
+	default:
+		return "\\" + string(c)
+	}
+}
+
+
+func cutLine(src string) (string, string) {
+	line, rest, found := strings.Cut(src, "\n")
+	if !found {
+		return line, ""
+	}
+	return line, rest
+}
+
+
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package parser
+
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+
+// HCLDecoder reads HCL files such as Terraform .tfvars. Attributes become
+// keys, and a block becomes a nested map under its type and then each of its
+// labels, so resource "aws_instance" "web" { ... } ends up at
+// resource.aws_instance.web. A block repeated under the same path
+// (ingress { ... } twice) collects into a list. Expressions that need
+// variables or functions (var.region, file("x")) can't be evaluated without
+// Terraform and are kept as their source text.
+type HCLDecoder struct{}
+
+
+func (HCLDecoder) Decode(data []byte) (map[string]any, error) {
+	file, diags := hclsyntax.ParseConfig(data, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid HCL: %w", diags)
+	}
+	return hclBody(file.Body.(*hclsyntax.Body), data)
+}
+
+
+// Detect accepts content that parses as HCL and whose attributes all have
+// literal values, so a line of prose or a dotenv file with bare words isn't
+// taken for HCL.
+func (HCLDecoder) Detect(data []byte) bool {
+	file, diags := hclsyntax.ParseConfig(data, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return false
+	}
+	body := file.Body.(*hclsyntax.Body)
+	if len(body.Attributes) == 0 && len(body.Blocks) == 0 {
+		return false
+	}
+	return literalBody(body)
+}
+
+
+func hclBody(body *hclsyntax.Body, src []byte) (map[string]any, error) {
+	result := make(map[string]any, len(body.Attributes))
+
+	for name, attr := range body.Attributes {
+		value, err := hclExpr(attr.Expr, src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HCL: attribute %s: %w", name, err)
+		}
+		result[name] = value
+	}
+
+	for _, block := range body.Blocks {
+		content, err := hclBody(block.Body, src)
+		if err != nil {
+			return nil, err
+		}
+
+		path := append([]string{block.Type}, block.Labels...)
+		node := result
+		for _, part := range path[:len(path)-1] {
+			next, ok := node[part].(map[string]any)
+			if !ok {
+				if _, exists := node[part]; exists {
+					return nil, fmt.Errorf("invalid HCL: line %d: block %s conflicts with attribute %s",
+						block.TypeRange.Start.Line, strings.Join(path, "."), part)
+				}
+				next = make(map[string]any)
+				node[part] = next
+			}
+			node = next
+		}
+
+		last := path[len(path)-1]
+		switch existing := node[last].(type) {
+		case nil:
+			node[last] = content
+		case []any:
+			node[last] = append(existing, content)
+		default:
+			node[last] = []any{existing, content}
+		}
+	}
+
+	return result, nil
+}
+
+
+func hclExpr(expr hclsyntax.Expression, src []byte) (any, error) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return strings.TrimSpace(string(expr.Range().SliceBytes(src))), nil
+	}
+	return ctyValue(value)
+}
+
+
+func ctyValue(v cty.Value) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	if !v.IsKnown() {
+		return nil, fmt.Errorf("unknown value")
+	}
+
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return v.AsString(), nil
+	case t == cty.Bool:
+		return v.True(), nil
+	case t == cty.Number:
+		return ctyNumber(v.AsBigFloat()), nil
+	case t.IsListType() || t.IsTupleType() || t.IsSetType():
+		list := make([]any, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			item, err := ctyValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case t.IsMapType() || t.IsObjectType():
+		m := make(map[string]any, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			item, err := ctyValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[key.AsString()] = item
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s", t.FriendlyName())
+	}
+}
+
+
+// ctyNumber converts an HCL number: int64 when it is an integer that fits,
+// otherwise what decimal makes of it, so large values keep their digits.
+func ctyNumber(f *big.Float) any {
+	if f.IsInt() {
+		n, _ := f.Int(nil)
+		if n.IsInt64() {
+			return n.Int64()
+		}
+		return decimal(n.String())
+	}
+	return decimal(f.Text('g', -1))
+}
+
+
+func literalBody(body *hclsyntax.Body) bool {
+	for _, attr := range body.Attributes {
+		if _, diags := attr.Expr.Value(nil); diags.HasErrors() {
+			return false
+		}
+	}
+	for _, block := range body.Blocks {
+		if !literalBody(block.Body) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package parser
+
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+
+// INIDecoder reads INI files such as php.ini or my.cnf. Keys before the
+// first [section] go to the top level, every section becomes a nested map
+// and values are strings. A key without a value (skip-name-resolve) gets an
+// empty string, and a key repeated within a section (extension=...) collects
+// its values into a list. Lines starting with ';' or '#' are comments, as
+// is anything after " ;" or " #" in an unquoted value.
+type INIDecoder struct {
+	// CoerceTypes turns true/false, yes/no and on/off into booleans, numbers
+	// into int64 or float64 (json.Number if that would round them), and keys
+	// without a value into true.
+	CoerceTypes bool
+}
+
+
+func (d INIDecoder) Decode(data []byte) (map[string]any, error) {
+	return d.decode(data, nil)
+}
+
+
+// decode parses data, calling onKey, if set, with the path of each section
+// header and key and the line it is on.
+func (d INIDecoder) decode(data []byte, onKey func(path string, line int)) (map[string]any, error) {
+	result := make(map[string]any)
+	sectionName := ""
+	section := result
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			name, ok := iniSectionName(line)
+			if !ok {
+				return nil, fmt.Errorf("invalid INI: line %d: malformed section header %q", lineNo, line)
+			}
+			existing, ok := result[name].(map[string]any)
+			if !ok {
+				existing = make(map[string]any)
+				result[name] = existing
+			}
+			section = existing
+			sectionName = name
+			if onKey != nil {
+				onKey(name, lineNo)
+			}
+			continue
+		}
+
+		key, value, hasValue := splitINILine(line)
+		if key == "" {
+			return nil, fmt.Errorf("invalid INI: line %d: missing key in %q", lineNo, line)
+		}
+		if onKey != nil {
+			onKey(keyPath(sectionName, key), lineNo)
+		}
+		addINIValue(section, key, d.value(value, hasValue))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid INI: %w", err)
+	}
+	return result, nil
+}
+
+
+// Detect accepts content made only of comments, section headers, key=value
+// lines and bare keys, with at least one assignment, so neither a line of
+// prose nor a JSON array is mistaken for INI.
+func (INIDecoder) Detect(data []byte) bool {
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+		case line[0] == '[':
+			if _, ok := iniSectionName(line); !ok {
+				return false
+			}
+		case strings.ContainsAny(line, "=:"):
+			found = true
+		case strings.ContainsAny(line, " \t"):
+			return false
+		}
+	}
+	return found
+}
+
+
+func (d INIDecoder) value(raw string, hasValue bool) any {
+	if !d.CoerceTypes {
+		return raw
+	}
+	if !hasValue {
+		return true
+	}
+
+	switch strings.ToLower(raw) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return n
+	}
+	if jsonNumberPattern.MatchString(raw) {
+		return decimal(raw)
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	return raw
+}
+
+
+func iniSectionName(line string) (string, bool) {
+	end := strings.IndexByte(line, ']')
+	if end < 0 {
+		return "", false
+	}
+	rest := strings.TrimSpace(line[end+1:])
+	if rest != "" && rest[0] != ';' && rest[0] != '#' {
+		return "", false
+	}
+	return strings.TrimSpace(line[1:end]), true
+}
+
+
+func splitINILine(line string) (string, string, bool) {
+	sep := strings.IndexAny(line, "=:")
+	if sep < 0 {
+		return line, "", false
+	}
+
+	key := strings.TrimSpace(line[:sep])
+	value := strings.TrimSpace(line[sep+1:])
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return key, value[1 : end+1], true
+		}
+	}
+
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = strings.TrimSpace(value[:i])
+			break
+		}
+	}
+	return key, value, true
+}
+
+
+func addINIValue(section map[string]any, key string, value any) {
+	switch existing := section[key].(type) {
+	case nil:
+		section[key] = value
+	case []any:
+		section[key] = append(existing, value)
+	default:
+		section[key] = []any{existing, value}
+	}
+}
@@ -0,0 +1,445 @@
+package parser
+
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+
+// JSON5Decoder reads JSON5, and therefore also JSONC as found in
+// tsconfig.json or VS Code settings: JSON plus // and /* */ comments,
+// trailing commas, unquoted identifier keys, single-quoted strings,
+// hexadecimal numbers, leading or trailing decimal points, an explicit '+'
+// sign, Infinity and NaN. Numbers become float64, or json.Number when that
+// would round them, as with plain JSON.
+type JSON5Decoder struct{}
+
+
+func (JSON5Decoder) Decode(data []byte) (map[string]any, error) {
+	p := &json5Parser{src: string(data)}
+	return p.document()
+}
+
+
+// Detect accepts content that starts with '{' and is valid JSON5.
+func (d JSON5Decoder) Detect(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	_, err := d.Decode(data)
+	return err == nil
+}
+
+
+// json5Parser parses src by recursive descent. With strict set it also
+// records the ambiguities Check reports, path being that of the value being
+// parsed.
+type json5Parser struct {
+	src string
+	pos int
+	err error
+
+	strict bool
+	path   string
+	issues []*StrictError
+}
+
+
+func (p *json5Parser) document() (map[string]any, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return nil, p.errorf("expected an object at the top level")
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q after the top-level object", p.peek())
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return value.(map[string]any), nil
+}
+
+
+// errorf reports a syntax error at the current position, unless an earlier
+// one, such as an unterminated comment, already ended the input.
+func (p *json5Parser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	pos := p.position(p.pos)
+	return fmt.Errorf("invalid JSON5: line %d, column %d: %s", pos.Line, pos.Column, fmt.Sprintf(format, args...))
+}
+
+
+func (p *json5Parser) position(offset int) Position {
+	return offsetPosition([]byte(p.src), offset)
+}
+
+
+// report records an ambiguity found at offset when parsing strictly.
+func (p *json5Parser) report(kind StrictKind, path string, offset int) {
+	if p.strict {
+		p.issues = append(p.issues, &StrictError{Kind: kind, Path: path, Position: p.position(offset)})
+	}
+}
+
+
+func (p *json5Parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+
+// skipSpace skips whitespace and comments, recording an unterminated block
+// comment as the parser's error.
+func (p *json5Parser) skipSpace() {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		switch {
+		case unicode.IsSpace(r) || r == '\uFEFF':
+			p.pos += size
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end + 1
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end < 0 {
+				p.err = p.errorf("unterminated comment")
+				p.pos = len(p.src)
+			} else {
+				p.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+
+func (p *json5Parser) value() (any, error) {
+	p.skipSpace()
+	start := p.pos
+	switch c := p.peek(); {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"' || c == '\'':
+		return p.string()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		n, err := p.number()
+		if f, ok := n.(float64); ok && isNonFinite(f) {
+			p.report(NonFiniteNumber, p.path, start)
+		}
+		return n, err
+	case c == 0:
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	word := p.identifier()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "Infinity":
+		p.report(NonFiniteNumber, p.path, start)
+		return math.Inf(1), nil
+	case "NaN":
+		p.report(NonFiniteNumber, p.path, start)
+		return math.NaN(), nil
+	case "":
+		return nil, p.errorf("unexpected %q", p.peek())
+	default:
+		return nil, p.errorf("unexpected identifier %q", word)
+	}
+}
+
+
+func (p *json5Parser) object() (any, error) {
+	p.pos++
+	result := make(map[string]any)
+	parent := p.path
+	defer func() { p.path = parent }()
+
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return result, nil
+		}
+
+		start := p.pos
+		var key string
+		if c := p.peek(); c == '"' || c == '\'' {
+			s, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		} else if key = p.identifier(); key == "" {
+			return nil, p.errorf("expected a key, got %q", p.peek())
+		}
+
+		p.skipSpace()
+		if p.peek() != ':' {
+			return nil, p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+
+		p.path = keyPath(parent, key)
+		if _, dup := result[key]; dup {
+			p.report(DuplicateKey, p.path, start)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}' after the value of %q", key)
+		}
+	}
+}
+
+
+func (p *json5Parser) array() (any, error) {
+	p.pos++
+	result := []any{}
+	parent := p.path
+	defer func() { p.path = parent }()
+
+	for {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			return result, nil
+		}
+
+		p.path = indexPath(parent, len(result))
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+
+func (p *json5Parser) string() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n':
+			return "", p.errorf("unterminated string")
+		case c == '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+
+func (p *json5Parser) escape(b *strings.Builder) error {
+	p.pos++
+	if p.pos >= len(p.src) {
+		return p.errorf("unterminated string")
+	}
+
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'v':
+		b.WriteByte('\v')
+	case '0':
+		b.WriteByte(0)
+	case '\r':
+		// A line continuation: \ followed by CRLF is dropped along with it.
+		if p.peek() == '\n' {
+			p.pos++
+		}
+	case '\n':
+	case 'x':
+		code, err := p.hex(2)
+		if err != nil {
+			return err
+		}
+		b.WriteRune(rune(code))
+	case 'u':
+		code, err := p.hex(4)
+		if err != nil {
+			return err
+		}
+		r := rune(code)
+		if utf16.IsSurrogate(r) && strings.HasPrefix(p.src[p.pos:], "\\u") {
+			p.pos += 2
+			low, err := p.hex(4)
+			if err != nil {
+				return err
+			}
+			r = utf16.DecodeRune(r, rune(low))
+		}
+		b.WriteRune(r)
+	default:
+		b.WriteByte(c)
+	}
+	return nil
+}
+
+
+func (p *json5Parser) hex(digits int) (uint64, error) {
+	if p.pos+digits > len(p.src) {
+		return 0, p.errorf("malformed escape")
+	}
+	code, err := strconv.ParseUint(p.src[p.pos:p.pos+digits], 16, 32)
+	if err != nil {
+		return 0, p.errorf("malformed escape")
+	}
+	p.pos += digits
+	return code, nil
+}
+
+
+func (p *json5Parser) number() (any, error) {
+	start := p.pos
+	sign := 1.0
+	if c := p.peek(); c == '+' || c == '-' {
+		if c == '-' {
+			sign = -1
+		}
+		p.pos++
+	}
+
+	switch word := p.identifier(); word {
+	case "Infinity":
+		return math.Inf(int(sign)), nil
+	case "NaN":
+		return math.NaN(), nil
+	case "":
+	default:
+		p.pos -= len(word)
+	}
+
+	rest := p.src[p.pos:]
+	if strings.HasPrefix(rest, "0x") || strings.HasPrefix(rest, "0X") {
+		p.pos += 2
+		digits := p.pos
+		for p.pos < len(p.src) && strings.IndexByte("0123456789abcdefABCDEF", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, ok := new(big.Int).SetString(p.src[digits:p.pos], 16)
+		if !ok {
+			return nil, p.errorf("malformed number %q", p.src[start:p.pos])
+		}
+		if sign < 0 {
+			n.Neg(n)
+		}
+		return decimal(n.String()), nil
+	}
+
+	for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+		if c := p.src[p.pos]; (c == '+' || c == '-') && p.src[p.pos-1] != 'e' && p.src[p.pos-1] != 'E' {
+			break
+		}
+		p.pos++
+	}
+	text := strings.TrimPrefix(strings.TrimPrefix(p.src[start:p.pos], "+"), "-")
+	n, err := strconv.ParseFloat(text, 64)
+	if text == "" || (err != nil && !errors.Is(err, strconv.ErrRange)) {
+		return nil, p.errorf("malformed number %q", p.src[start:p.pos])
+	}
+	if exact := jsonDecimal(sign < 0, text); jsonNumberPattern.MatchString(exact) {
+		return decimal(exact), nil
+	}
+	return sign * n, nil
+}
+
+
+// jsonDecimal rewrites an unsigned JSON5 decimal such as ".5" or "5." the
+// way JSON writes it.
+func jsonDecimal(negative bool, text string) string {
+	mantissa, exp, hasExp := strings.Cut(strings.ToLower(text), "e")
+	mantissa = strings.TrimSuffix(mantissa, ".")
+	if strings.HasPrefix(mantissa, ".") {
+		mantissa = "0" + mantissa
+	}
+	if negative {
+		mantissa = "-" + mantissa
+	}
+	if hasExp {
+		return mantissa + "e" + exp
+	}
+	return mantissa
+}
+
+
+func (p *json5Parser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !(p.pos > start && unicode.IsDigit(r)) {
+			break
+		}
+		p.pos += size
+	}
+	return p.src[start:p.pos]
+}
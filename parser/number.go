@@ -0,0 +1,190 @@
+package parser
+
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+
+// jsonNumberPattern matches a number as JSON writes it.
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+
+// decimal turns text, a number in JSON syntax, into a value of the tree: a
+// float64 when that is the same number, as it is for all but very large
+// integers and very long fractions, and otherwise a json.Number holding
+// text, so no digits are silently lost.
+func decimal(text string) any {
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || !sameNumber(text, f) {
+		return json.Number(text)
+	}
+	return f
+}
+
+
+// sameNumber reports whether f, written in as few digits as it takes to
+// read it back, is the number text.
+func sameNumber(text string, f float64) bool {
+	if f == 0 {
+		mantissa, _, _ := strings.Cut(strings.ToLower(text), "e")
+		return strings.Trim(mantissa, "-+0.") == ""
+	}
+
+	exact, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return false
+	}
+	shortest, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return exact.Cmp(shortest) == 0
+}
+
+
+// exactNumbers replaces the json.Numbers in v, as decoded with UseNumber,
+// by what decimal makes of them.
+func exactNumbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, x := range t {
+			t[k] = exactNumbers(x)
+		}
+	case []any:
+		for i, x := range t {
+			t[i] = exactNumbers(x)
+		}
+	case json.Number:
+		return decimal(string(t))
+	}
+	return v
+}
+
+
+// yamlNumber is the exact value of a YAML number that yaml.v3 would round
+// to a float64, or read as a string for being out of range, or nil if it
+// decodes exactly. Integers may use the 0x, 0o and 0b prefixes; they are
+// written out in decimal.
+func yamlNumber(node *yaml.Node) any {
+	tag := node.ShortTag()
+	if tag == "!!str" && node.Style == 0 {
+		tag = ""
+	}
+	if tag != "" && tag != "!!int" && tag != "!!float" {
+		return nil
+	}
+
+	text := strings.ReplaceAll(strings.TrimPrefix(node.Value, "+"), "_", "")
+	if tag != "!!float" {
+		if n, ok := yamlInteger(text); ok {
+			if n.IsInt64() || n.IsUint64() {
+				return nil
+			}
+			return json.Number(n.String())
+		}
+	}
+
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "-.") {
+		text = strings.Replace(text, ".", "0.", 1)
+	}
+	if tag != "!!int" && jsonNumberPattern.MatchString(text) {
+		if n, ok := decimal(text).(json.Number); ok {
+			return n
+		}
+	}
+	return nil
+}
+
+
+func yamlInteger(text string) (*big.Int, bool) {
+	digits := strings.TrimPrefix(text, "-")
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x"):
+		base = 16
+	case strings.HasPrefix(digits, "0o"):
+		base = 8
+	case strings.HasPrefix(digits, "0b"):
+		base = 2
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, false
+	}
+	if strings.HasPrefix(text, "-") {
+		n.Neg(n)
+	}
+	return n, true
+}
+
+
+// exactYAMLNumbers puts the exact value of each number below node that
+// yaml.v3 rounded into v, the value decoded from node, and returns v.
+// Merged keys take their value from the first mapping that sets them, as in
+// the decoder.
+func exactYAMLNumbers(node *yaml.Node, v any) any {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return exactYAMLNumbers(node.Content[0], v)
+		}
+
+	case yaml.AliasNode:
+		return exactYAMLNumbers(node.Alias, v)
+
+	case yaml.ScalarNode:
+		if n := yamlNumber(node); n != nil {
+			return n
+		}
+
+	case yaml.SequenceNode:
+		if list, ok := v.([]any); ok && len(list) == len(node.Content) {
+			for i, item := range node.Content {
+				list[i] = exactYAMLNumbers(item, list[i])
+			}
+		}
+
+	case yaml.MappingNode:
+		if m, ok := v.(map[string]any); ok {
+			exactYAMLMapping(node, m, make(map[string]bool))
+		}
+	}
+	return v
+}
+
+
+func exactYAMLMapping(node *yaml.Node, m map[string]any, seen map[string]bool) {
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if isMergeKey(key) {
+			if value.Kind == yaml.SequenceNode {
+				merged = append(merged, value.Content...)
+			} else {
+				merged = append(merged, value)
+			}
+			continue
+		}
+		if x, ok := m[key.Value]; ok && !seen[key.Value] {
+			seen[key.Value] = true
+			m[key.Value] = exactYAMLNumbers(value, x)
+		}
+	}
+
+	for _, src := range merged {
+		if src.Kind == yaml.AliasNode {
+			src = src.Alias
+		}
+		if src != nil && src.Kind == yaml.MappingNode {
+			exactYAMLMapping(src, m, seen)
+		}
+	}
+}
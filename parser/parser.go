@@ -2,59 +2,319 @@ package parser
 
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v3"
 )
 
 
+// DocumentsKey is the key under which Parse returns a YAML file with several
+// "---"-separated documents, its documents forming a list. The result cannot
+// be told from a single document with a "documents" key; ParseDocuments
+// keeps the documents apart.
+const DocumentsKey = "documents"
+
+
+func init() {
+	Register("json", []string{".json"}, jsonDecoder{})
+	Register("toml", []string{".toml"}, tomlDecoder{})
+	Register("yaml", []string{".yaml", ".yml"}, YAMLDecoder{})
+	Register("json5", []string{".json5", ".jsonc"}, JSON5Decoder{})
+	Register("xml", []string{".xml", ".config"}, XMLDecoder{})
+	Register("dotenv", []string{".env"}, DotenvDecoder{})
+	Register("properties", []string{".properties"}, PropertiesDecoder{})
+	Register("ini", []string{".ini", ".cfg", ".cnf"}, INIDecoder{})
+	Register("hcl", []string{".hcl", ".tfvars"}, HCLDecoder{})
+}
+
+
 func ParseFile(path string) (map[string]any, error) {
+	result, _, err := ParseFileWithFormat(path)
+	return result, err
+}
+
+
+// ParseFileWithFormat is ParseFile that also returns the format the file
+// was parsed as. Files without a known extension are recognised by their
+// content, see FileFormat.
+func ParseFileWithFormat(path string) (map[string]any, string, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+		return nil, "", fmt.Errorf("invalid path '%s': %w", path, err)
 	}
 
 	data, err := os.ReadFile(absPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file '%s': %w", absPath, err)
+		return nil, "", fmt.Errorf("failed to read file '%s': %w", absPath, err)
+	}
+
+	format, err := FileFormat(absPath, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := parse(data, format)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, format, nil
+}
+
+
+// FileFormat is the format ParseFile reads data, the content of the file at
+// path, as: the one registered for its extension, or else the one
+// DetectFormat recognises. A known extension is authoritative, so YAML saved
+// as .json fails to parse rather than being read as YAML: the line-based
+// formats would accept a broken JSON file too, and report no error at all.
+// Use Parse with an explicit format to read such a file.
+func FileFormat(path string, data []byte) (string, error) {
+	ext := filepath.Ext(path)
+	if format, ok := formatForExt(ext); ok {
+		return format, nil
 	}
 
-	ext := filepath.Ext(absPath)
-	switch ext {
-	case ".json":
-		return parseJSON(data)
-	case ".yaml", ".yml":
-		return parseYAML(data)
-	case ".toml":
-		return parseTOML(data)
-	default:
-		return nil, fmt.Errorf("unsupported file format: %s (expected .json, .yaml, .yml, .toml)", ext)
+	format, err := DetectFormat(data)
+	if err != nil {
+		return "", fmt.Errorf("unsupported file format: %s (expected %s): %w",
+			ext, strings.Join(registeredExts(), ", "), err)
 	}
+	return format, nil
 }
 
 
+// Parse reads r and parses it as format. An empty format is detected from
+// the content.
+func Parse(r io.Reader, format string) (map[string]any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if format == "" {
+		if format, err = DetectFormat(data); err != nil {
+			return nil, err
+		}
+	}
+	return parse(data, format)
+}
+
+
+// ParseDocuments is Parse that returns each document of a multi-document
+// YAML file on its own, leaving out the empty ones. Other formats, and
+// decoders that are not a DocumentsDecoder, give a single document.
+func ParseDocuments(r io.Reader, format string) ([]map[string]any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if format == "" {
+		if format, err = DetectFormat(data); err != nil {
+			return nil, err
+		}
+	}
+	f, ok := lookupFormat(format)
+	if !ok {
+		return nil, unsupportedFormatError(format)
+	}
+	return DecodeDocuments(f.decoder, data)
+}
+
+
+// DecodeDocuments is ParseDocuments with dec in place of a registered
+// decoder, for callers that configure one for a single call.
+func DecodeDocuments(dec Decoder, data []byte) ([]map[string]any, error) {
+	if d, ok := dec.(DocumentsDecoder); ok {
+		return d.DecodeDocuments(data)
+	}
+	result, err := dec.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]any{result}, nil
+}
+
+
+func parse(data []byte, format string) (map[string]any, error) {
+	f, ok := lookupFormat(format)
+	if !ok {
+		return nil, unsupportedFormatError(format)
+	}
+	return f.decoder.Decode(data)
+}
+
+
+// jsonDecoder parses strict JSON and falls back to JSON5 for .json files
+// that carry comments or trailing commas, such as tsconfig.json.
+type jsonDecoder struct{}
+
+
+func (jsonDecoder) Decode(data []byte) (map[string]any, error) {
+	result, err := parseJSON(data)
+	if err == nil {
+		return result, nil
+	}
+	if lenient, lenientErr := (JSON5Decoder{}).Decode(data); lenientErr == nil {
+		return lenient, nil
+	}
+	return nil, err
+}
+
+
+func (jsonDecoder) Detect(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte("{")) && json.Valid(trimmed)
+}
+
+
+// parseJSON decodes data, keeping numbers that a float64 cannot hold
+// exactly as json.Number.
 func parseJSON(data []byte) (map[string]any, error) {
+	if !json.Valid(data) {
+		var discard any
+		return nil, fmt.Errorf("invalid JSON: %w", json.Unmarshal(data, &discard))
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
 	var result map[string]any
-	if err := json.Unmarshal(data, &result); err != nil {
+	if err := dec.Decode(&result); err != nil {
 		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
+	exactNumbers(result)
 	return result, nil
 }
 
 
+// YAMLDecoder is the YAML decoder, see parseYAML. A key repeated in a
+// mapping is an error unless LastKeyWins is set, in which case it takes its
+// last value, as in JSON; Check reports it either way.
+type YAMLDecoder struct {
+	LastKeyWins bool
+}
+
+
+func (d YAMLDecoder) Decode(data []byte) (map[string]any, error) {
+	docs, err := yamlDocumentsData(data, d.LastKeyWins)
+	if err != nil {
+		return nil, err
+	}
+	return joinYAMLDocuments(docs), nil
+}
+
+
+func (d YAMLDecoder) DecodeDocuments(data []byte) ([]map[string]any, error) {
+	return yamlDocumentsData(data, d.LastKeyWins)
+}
+
+
+// parseYAML decodes every document in data. A single document is returned
+// as is; several are returned as a list under DocumentsKey, so none of them
+// is silently dropped.
 func parseYAML(data []byte) (map[string]any, error) {
-	var result map[string]any
-	if err := yaml.Unmarshal(data, &result); err != nil {
-		return nil, fmt.Errorf("invalid YAML: %w", err)
+	return YAMLDecoder{}.Decode(data)
+}
+
+
+func joinYAMLDocuments(docs []map[string]any) map[string]any {
+	switch len(docs) {
+	case 0:
+		return nil
+	case 1:
+		return docs[0]
 	}
-	return result, nil
+
+	list := make([]any, len(docs))
+	for i, doc := range docs {
+		list[i] = doc
+	}
+	return map[string]any{DocumentsKey: list}
+}
+
+
+// yamlDocumentsData decodes each document in data, skipping empty ones.
+// Numbers that a float64 cannot hold exactly are kept as json.Number.
+// Repeated keys fail as they do in yaml.v3, or with lastKeyWins take their
+// last value.
+func yamlDocumentsData(data []byte, lastKeyWins bool) ([]map[string]any, error) {
+	var docs []map[string]any
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for n := 1; ; n++ {
+		var node yaml.Node
+		var doc map[string]any
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			if lastKeyWins {
+				dropDuplicateKeys(&node)
+			}
+			err = node.Decode(&doc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: document %d: %w", n, err)
+		}
+		exactYAMLNumbers(&node, doc)
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+
+// dropDuplicateKeys removes all but the last occurrence of each key the
+// mappings below node repeat, which yaml.v3 would otherwise reject. Aliases
+// are not followed; the mapping they refer to is handled where it is
+// defined.
+func dropDuplicateKeys(node *yaml.Node) {
+	for _, child := range node.Content {
+		dropDuplicateKeys(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	last := make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i]; key.Kind == yaml.ScalarNode && !isMergeKey(key) {
+			last[key.Value] = i
+		}
+	}
+
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if key.Kind == yaml.ScalarNode && !isMergeKey(key) && last[key.Value] != i {
+			continue
+		}
+		content = append(content, key, node.Content[i+1])
+	}
+	node.Content = content
+}
+
+
+// tomlDecoder is the TOML decoder, see parseTOML.
+type tomlDecoder struct{}
+
+
+func (tomlDecoder) Decode(data []byte) (map[string]any, error) {
+	return parseTOML(data)
 }
 
 
+// parseTOML decodes data. TOML integers are 64-bit by the specification,
+// so larger ones are an error rather than rounded like a float.
 func parseTOML(data []byte) (map[string]any, error) {
 	var result map[string]any
 	if err := toml.Unmarshal(data, &result); err != nil {
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -384,3 +387,897 @@ func TestParseFile_PathNormalization(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, map[string]any{"key": "value"}, result)
 }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		expected    map[string]any
+		expectError bool
+		errorMsg    string
+	}{
+		{
+			name:     "json",
+			format:   "json",
+			input:    `{"host": "` + testHost + `"}`,
+			expected: map[string]any{"host": testHost},
+		},
+		{
+			name:     "yaml",
+			format:   "yaml",
+			input:    "timeout: 50\n",
+			expected: map[string]any{"timeout": 50},
+		},
+		{
+			name:     "yml alias in upper case",
+			format:   "YML",
+			input:    "timeout: 50\n",
+			expected: map[string]any{"timeout": 50},
+		},
+		{
+			name:     "toml",
+			format:   "toml",
+			input:    "timeout = 50\n",
+			expected: map[string]any{"timeout": int64(50)},
+		},
+		{
+			name:        "invalid content",
+			format:      "json",
+			input:       `{"host": `,
+			expectError: true,
+			errorMsg:    "invalid JSON",
+		},
+		{
+			name:        "unknown format",
+			format:      "csv",
+			input:       "a,b\n1,2",
+			expectError: true,
+			errorMsg:    "unsupported input format: csv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(strings.NewReader(tt.input), tt.format)
+
+			if tt.expectError {
+				require.Error(t, err)
+				assert.Nil(t, result)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{name: "json object", input: ` {"host": "` + testHost + `"}`, expected: "json"},
+		{name: "toml", input: "host = \"" + testHost + "\"\n[db]\nport = 5432\n", expected: "toml"},
+		{name: "yaml", input: "host: " + testHost + "\ndb:\n  port: 5432\n", expected: "yaml"},
+		{name: "yaml flow mapping", input: "{host: " + testHost + "}", expected: "yaml"},
+		{name: "json array is not a config", input: `[1, 2]`, expectError: true},
+		{name: "prose", input: "just some words", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectFormat([]byte(tt.input))
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestParseFileWithFormat_ContentSniffing(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		filename string
+		content  string
+		format   string
+	}{
+		{filename: "config", content: `{"key": "value"}`, format: "json"},
+		{filename: "app.conf", content: "key = \"value\"\n", format: "toml"},
+		{filename: "Dockerfile.json.tmpl", content: `{"key": "value"}`, format: "json"},
+		{filename: ".prettierrc", content: "key: value\n", format: "yaml"},
+		{filename: "settings.yml", content: "key: value\n", format: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			path := createTempFile(t, tmpDir, tt.filename, tt.content)
+
+			result, format, err := ParseFileWithFormat(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+			assert.Equal(t, map[string]any{"key": "value"}, result)
+		})
+	}
+}
+
+func TestParseFileWithFormat_ExtensionIsAuthoritative(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	path := createTempFile(t, tmpDir, "saved-as.json", "key: value\n")
+	format, err := FileFormat(path, []byte("key: value\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "json", format)
+
+	_, _, err = ParseFileWithFormat(path)
+	assert.ErrorContains(t, err, "invalid JSON")
+}
+
+func TestParse_DetectsEmptyFormat(t *testing.T) {
+	result, err := Parse(strings.NewReader("key: value\n"), "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"key": "value"}, result)
+}
+
+type keyValueDecoder struct{}
+
+func (keyValueDecoder) Decode(data []byte) (map[string]any, error) {
+	result := map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, ok := strings.Cut(line, "->")
+		if !ok {
+			return nil, assert.AnError
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
+
+func (keyValueDecoder) Detect(data []byte) bool {
+	return strings.Contains(string(data), "->")
+}
+
+func TestRegister(t *testing.T) {
+	Register("arrows", []string{".arrows", ".ARW"}, keyValueDecoder{})
+
+	assert.Equal(t, []string{"json", "toml", "yaml"}, Formats()[:3], "Встроенные форматы должны идти первыми")
+	assert.Contains(t, Formats(), "arrows")
+
+	tmpDir := t.TempDir()
+	for _, name := range []string{"config.arrows", "config.arw", "config"} {
+		path := createTempFile(t, tmpDir, name, "key -> value\n")
+		result, format, err := ParseFileWithFormat(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, "arrows", format, name)
+		assert.Equal(t, map[string]any{"key": "value"}, result, name)
+	}
+
+	result, err := Parse(strings.NewReader("a -> b"), "ARROWS")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "b"}, result)
+
+	Register("arrows", []string{".arrows"}, DecoderFunc(func([]byte) (map[string]any, error) {
+		return map[string]any{"replaced": true}, nil
+	}))
+	result, err = Parse(strings.NewReader("a -> b"), "arrows")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"replaced": true}, result)
+	assert.Equal(t, 1, strings.Count(strings.Join(Formats(), ","), "arrows"))
+	Register("arrows", []string{".arrows", ".ARW"}, keyValueDecoder{})
+
+	assert.Panics(t, func() { Register("", nil, keyValueDecoder{}) })
+	assert.Panics(t, func() { Register("nil", nil, nil) })
+}
+
+func TestINIDecoder(t *testing.T) {
+	input := `; php.ini style comment
+engine = On
+# another comment
+[PHP]
+memory_limit = 128M ; inline comment
+error_reporting = E_ALL & ~E_DEPRECATED
+extension = mysqli
+extension = pdo
+display_errors = "Off ; not a comment"
+
+[mysqld]
+port: 3306
+ratio = 0.75
+skip-name-resolve
+`
+
+	result, err := INIDecoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"engine": "On",
+		"PHP": map[string]any{
+			"memory_limit":    "128M",
+			"error_reporting": "E_ALL & ~E_DEPRECATED",
+			"extension":       []any{"mysqli", "pdo"},
+			"display_errors":  "Off ; not a comment",
+		},
+		"mysqld": map[string]any{
+			"port":              "3306",
+			"ratio":             "0.75",
+			"skip-name-resolve": "",
+		},
+	}, result)
+
+	typed, err := INIDecoder{CoerceTypes: true}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, true, typed["engine"])
+	assert.Equal(t, map[string]any{
+		"port":              int64(3306),
+		"ratio":             0.75,
+		"skip-name-resolve": true,
+	}, typed["mysqld"])
+
+	_, err = INIDecoder{}.Decode([]byte("[broken\nkey = value"))
+	assert.ErrorContains(t, err, "invalid INI: line 1")
+
+	_, err = INIDecoder{}.Decode([]byte("= value"))
+	assert.ErrorContains(t, err, "missing key")
+}
+
+func TestParseFile_INI(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "[client]\nhost = localhost\n"
+	expected := map[string]any{"client": map[string]any{"host": "localhost"}}
+
+	for _, name := range []string{"my.cnf", "setup.cfg", "php.ini"} {
+		result, format, err := ParseFileWithFormat(createTempFile(t, tmpDir, name, content))
+		require.NoError(t, err, name)
+		assert.Equal(t, "ini", format)
+		assert.Equal(t, expected, result)
+	}
+
+	format, err := DetectFormat([]byte("[client]\nhost = localhost\nskip-networking\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "ini", format)
+}
+
+func TestDotenvDecoder(t *testing.T) {
+	input := `# database
+DB_HOST=localhost
+export DB_PORT = 5432
+EMPTY=
+PLAIN=value # trailing comment
+HASH=abc#def
+SINGLE='literal \n $HOME'
+DOUBLE="line1\nline2 \"quoted\" \$HOME"
+MULTI="first
+second"
+AFTER=done
+`
+
+	result, err := DotenvDecoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"EMPTY":   "",
+		"PLAIN":   "value",
+		"HASH":    "abc#def",
+		"SINGLE":  `literal \n $HOME`,
+		"DOUBLE":  "line1\nline2 \"quoted\" $HOME",
+		"MULTI":   "first\nsecond",
+		"AFTER":   "done",
+	}, result)
+
+	nested, err := DotenvDecoder{NestSeparator: "__"}.Decode([]byte("DB__HOST=h\nDB__PORT=1\nAPP=x\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"DB":  map[string]any{"HOST": "h", "PORT": "1"},
+		"APP": "x",
+	}, nested)
+
+	_, err = DotenvDecoder{NestSeparator: "__"}.Decode([]byte("DB=x\nDB__HOST=h\n"))
+	assert.ErrorContains(t, err, "line 2: key DB__HOST conflicts with DB")
+
+	_, err = DotenvDecoder{}.Decode([]byte("A=1\nB=\"open\nC=3\n"))
+	assert.ErrorContains(t, err, "line 2: unterminated double-quoted value")
+
+	_, err = DotenvDecoder{}.Decode([]byte("not an assignment"))
+	assert.ErrorContains(t, err, "line 1: expected KEY=value")
+}
+
+func TestParseFile_Dotenv(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "export API_URL=https://example.com\nDEBUG=false\n"
+	expected := map[string]any{"API_URL": "https://example.com", "DEBUG": "false"}
+
+	for _, name := range []string{".env", "prod.env", ".env.local"} {
+		result, format, err := ParseFileWithFormat(createTempFile(t, tmpDir, name, content))
+		require.NoError(t, err, name)
+		assert.Equal(t, "dotenv", format, name)
+		assert.Equal(t, expected, result, name)
+	}
+}
+
+func TestPropertiesDecoder(t *testing.T) {
+	input := `# application settings
+! also a comment
+spring.datasource.url=jdbc:postgresql://localhost/app
+spring.datasource.user : admin
+server.port 8080
+greeting = Hello, \
+           World
+path=C:\\temp
+unicode=caf\u00e9
+key\ with\ spaces=x
+empty=
+`
+
+	result, err := PropertiesDecoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"spring.datasource.url":  "jdbc:postgresql://localhost/app",
+		"spring.datasource.user": "admin",
+		"server.port":            "8080",
+		"greeting":               "Hello, World",
+		"path":                   `C:\temp`,
+		"unicode":                "café",
+		"key with spaces":        "x",
+		"empty":                  "",
+	}, result)
+
+	nested, err := PropertiesDecoder{ExpandKeys: true}.Decode([]byte("db.host=h\ndb.port=1\napp=x\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"db":  map[string]any{"host": "h", "port": "1"},
+		"app": "x",
+	}, nested)
+
+	_, err = PropertiesDecoder{ExpandKeys: true}.Decode([]byte("db=x\ndb.host=h\n"))
+	assert.ErrorContains(t, err, "line 2: key db.host conflicts with db")
+
+	_, err = PropertiesDecoder{}.Decode([]byte("bad=\\u12"))
+	assert.ErrorContains(t, err, "line 1: malformed \\u escape")
+
+	emoji, err := PropertiesDecoder{}.Decode([]byte("smile=\\uD83D\\uDE00!\nlone=\\uD83Dx\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"smile": "\U0001F600!", "lone": "\uFFFDx"}, emoji)
+}
+
+func TestParseFile_Properties(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "spring.profiles.active=prod\nserver.port=8080\n"
+
+	result, format, err := ParseFileWithFormat(createTempFile(t, tmpDir, "application.properties", content))
+	require.NoError(t, err)
+	assert.Equal(t, "properties", format)
+	assert.Equal(t, map[string]any{"spring.profiles.active": "prod", "server.port": "8080"}, result)
+
+	format, err = DetectFormat([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "properties", format)
+}
+
+func TestHCLDecoder(t *testing.T) {
+	input := `# production
+region        = "us-east-1"
+instance_count = 3
+ratio          = 0.5
+enabled        = true
+zones          = ["a", "b"]
+tags = {
+  team = "infra"
+}
+ami = var.ami_id
+
+resource "aws_instance" "web" {
+  type = "t3.micro"
+}
+
+ingress {
+  port = 80
+}
+ingress {
+  port = 443
+}
+`
+
+	result, err := HCLDecoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"region":         "us-east-1",
+		"instance_count": int64(3),
+		"ratio":          0.5,
+		"enabled":        true,
+		"zones":          []any{"a", "b"},
+		"tags":           map[string]any{"team": "infra"},
+		"ami":            "var.ami_id",
+		"resource": map[string]any{
+			"aws_instance": map[string]any{
+				"web": map[string]any{"type": "t3.micro"},
+			},
+		},
+		"ingress": []any{
+			map[string]any{"port": int64(80)},
+			map[string]any{"port": int64(443)},
+		},
+	}, result)
+
+	_, err = HCLDecoder{}.Decode([]byte("region = \n"))
+	assert.ErrorContains(t, err, "invalid HCL")
+}
+
+func TestParseFile_HCL(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "region = \"eu-west-1\"\n\nnetwork {\n  cidr = \"10.0.0.0/16\"\n}\n"
+	expected := map[string]any{
+		"region":  "eu-west-1",
+		"network": map[string]any{"cidr": "10.0.0.0/16"},
+	}
+
+	for _, name := range []string{"prod.tfvars", "config.hcl"} {
+		result, format, err := ParseFileWithFormat(createTempFile(t, tmpDir, name, content))
+		require.NoError(t, err, name)
+		assert.Equal(t, "hcl", format, name)
+		assert.Equal(t, expected, result, name)
+	}
+
+	format, err := DetectFormat([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "hcl", format)
+}
+
+func TestXMLDecoder(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<!-- build -->
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <version>1.0</version>
+  <packaging/>
+  <dependencies>
+    <dependency scope="test"><artifactId>junit</artifactId></dependency>
+    <dependency><artifactId>guava</artifactId></dependency>
+  </dependencies>
+  <description lang="en">A <![CDATA[demo]]> app</description>
+</project>
+`
+
+	result, err := XMLDecoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"project": map[string]any{
+			"@xmlns":     "http://maven.apache.org/POM/4.0.0",
+			"@xmlns:xsi": "http://www.w3.org/2001/XMLSchema-instance",
+			"version":    "1.0",
+			"packaging":  "",
+			"dependencies": map[string]any{
+				"dependency": []any{
+					map[string]any{"@scope": "test", "artifactId": "junit"},
+					map[string]any{"artifactId": "guava"},
+				},
+			},
+			"description": map[string]any{"@lang": "en", "#text": "A demo app"},
+		},
+	}, result)
+
+	_, err = XMLDecoder{}.Decode([]byte("<a><b></a>"))
+	assert.ErrorContains(t, err, "invalid XML")
+
+	_, err = XMLDecoder{}.Decode([]byte("<a/><b/>"))
+	assert.ErrorContains(t, err, "more than one root element")
+}
+
+func TestParseFile_XML(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `<configuration><appSettings><add key="mode" value="prod"/></appSettings></configuration>`
+	expected := map[string]any{
+		"configuration": map[string]any{
+			"appSettings": map[string]any{
+				"add": map[string]any{"@key": "mode", "@value": "prod"},
+			},
+		},
+	}
+
+	for _, name := range []string{"pom.xml", "app.config"} {
+		result, format, err := ParseFileWithFormat(createTempFile(t, tmpDir, name, content))
+		require.NoError(t, err, name)
+		assert.Equal(t, "xml", format, name)
+		assert.Equal(t, expected, result, name)
+	}
+
+	format, err := DetectFormat([]byte(content))
+	require.NoError(t, err)
+	assert.Equal(t, "xml", format)
+}
+
+func TestJSON5Decoder(t *testing.T) {
+	input := `// tsconfig
+{
+  /* compiler settings */
+  compilerOptions: {
+    target: 'es2020',
+    strict: true, // trailing comment
+    paths: ["src", "lib",],
+  },
+  "hex": 0x1F,
+  half: .5,
+  plus: +3,
+  big: Infinity,
+  escaped: 'it\'s é\x21',
+}
+`
+
+	result, err := JSON5Decoder{}.Decode([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"compilerOptions": map[string]any{
+			"target": "es2020",
+			"strict": true,
+			"paths":  []any{"src", "lib"},
+		},
+		"hex":     31.0,
+		"half":    0.5,
+		"plus":    3.0,
+		"big":     math.Inf(1),
+		"escaped": "it's é!",
+	}, result)
+
+	_, err = JSON5Decoder{}.Decode([]byte("{\n  a: 1\n  b: 2\n}"))
+	assert.ErrorContains(t, err, "invalid JSON5: line 3, column 3: expected ',' or '}'")
+
+	_, err = JSON5Decoder{}.Decode([]byte("{a: 1} /* open"))
+	assert.ErrorContains(t, err, "unterminated comment")
+}
+
+func TestParseFile_JSONC(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "{\n  // editor\n  \"editor.tabSize\": 2,\n}\n"
+	expected := map[string]any{"editor.tabSize": 2.0}
+
+	for name, format := range map[string]string{"settings.jsonc": "json5", "config.json5": "json5", "tsconfig.json": "json"} {
+		result, got, err := ParseFileWithFormat(createTempFile(t, tmpDir, name, content))
+		require.NoError(t, err, name)
+		assert.Equal(t, format, got, name)
+		assert.Equal(t, expected, result, name)
+	}
+
+	result, err := Parse(strings.NewReader(content), "jsonc")
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	_, err = ParseFile(createTempFile(t, tmpDir, "broken.json", "{\"a\": }"))
+	assert.ErrorContains(t, err, "invalid JSON")
+}
+
+func TestParse_MultiDocumentYAML(t *testing.T) {
+	input := `---
+kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: web
+---
+`
+
+	result, err := Parse(strings.NewReader(input), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		DocumentsKey: []any{
+			map[string]any{"kind": "Service", "metadata": map[string]any{"name": "web"}},
+			map[string]any{"kind": "Deployment", "metadata": map[string]any{"name": "web"}},
+		},
+	}, result)
+
+	single, err := Parse(strings.NewReader("---\nkey: value\n"), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"key": "value"}, single)
+
+	_, err = Parse(strings.NewReader("a: 1\n---\nb: [\n"), "yaml")
+	assert.ErrorContains(t, err, "invalid YAML: document 2")
+
+	docs, err := ParseDocuments(strings.NewReader("documents: [a]\n---\n---\nb: 1\n"), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"documents": []any{"a"}}, {"b": 1}}, docs)
+
+	docs, err = ParseDocuments(strings.NewReader(`{"documents": []}`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"documents": []any{}}}, docs)
+}
+
+func TestParse_YAMLAnchors(t *testing.T) {
+	input := `base: &base
+  image: app:1
+  restart: always
+extra: &extra
+  restart: never
+  replicas: 2
+web:
+  <<: [*base, *extra]
+  port: 80
+api:
+  <<: *base
+  image: api:1
+copy: *base
+`
+
+	result, err := Parse(strings.NewReader(input), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"image": "app:1", "restart": "always", "replicas": 2, "port": 80}, result["web"])
+	assert.Equal(t, map[string]any{"image": "api:1", "restart": "always"}, result["api"])
+	assert.Equal(t, result["base"], result["copy"])
+
+	aliases, err := YAMLAliases([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"web.image":    "base.image",
+		"web.restart":  "base.restart",
+		"web.replicas": "extra.replicas",
+		"api.restart":  "base.restart",
+		"copy":         "base",
+	}, aliases)
+
+	multi, err := Aliases([]byte("a: 1\n---\nx: &x 1\ny: *x\n"), "yml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"documents[1].y": "documents[1].x"}, multi)
+
+	none, err := Aliases([]byte(`{"a": 1}`), "json")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestPositions(t *testing.T) {
+	tests := []struct {
+		format   string
+		input    string
+		expected map[string]Position
+	}{
+		{
+			format: "json",
+			input:  "{\n  \"a\": 1,\n  \"b\": {\"c\": [1, {\"d\": 2}]}\n}",
+			expected: map[string]Position{
+				"a": {2, 3}, "b": {3, 3}, "b.c": {3, 9}, "b.c[0]": {3, 15}, "b.c[1]": {3, 18}, "b.c[1].d": {3, 19},
+			},
+		},
+		{
+			format: "yaml",
+			input:  "base: &b\n  x: 1\nweb:\n  <<: *b\n  y: [1, 2]\n",
+			expected: map[string]Position{
+				"base": {1, 1}, "base.x": {2, 3}, "web": {3, 1}, "web.x": {4, 3},
+				"web.y": {5, 3}, "web.y[0]": {5, 7}, "web.y[1]": {5, 10},
+			},
+		},
+		{
+			format: "toml",
+			input:  "title = 'x'\n[owner]\ndob.day = 1\n[[products]]\nname = 'a'\n[[products]]\ntags = ['r', 's']\n",
+			expected: map[string]Position{
+				"title": {1, 1}, "owner": {2, 2}, "owner.dob": {3, 1}, "owner.dob.day": {3, 5},
+				"products": {4, 3}, "products[0]": {4, 3}, "products[0].name": {5, 1},
+				"products[1]": {6, 3}, "products[1].tags": {7, 1}, "products[1].tags[0]": {7, 9}, "products[1].tags[1]": {7, 14},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			positions, err := Positions([]byte(tt.input), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, positions)
+		})
+	}
+
+	none, err := Positions([]byte("A=1\n"), "dotenv")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestKeyOrder(t *testing.T) {
+	order, err := KeyOrder([]byte("version: 3\nservices:\n  web: {image: a}\n  db: {}\nalpha: 1\n"), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"":             {"version", "services", "alpha"},
+		"services":     {"web", "db"},
+		"services.web": {"image"},
+	}, order)
+
+	order, err = KeyOrder([]byte(`{"z": 1, "a": {"y": 2, "b": 3}}`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"": {"z", "a"}, "a": {"y", "b"}}, order)
+
+	order, err = KeyOrder([]byte("z = 1\n[b]\ny = 2\n[a]\nx = 3\n"), "toml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"": {"z", "b", "a"}, "b": {"y"}, "a": {"x"}}, order)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		format   string
+		input    string
+		expected []*StrictError
+	}{
+		{
+			format: "json",
+			input:  "{\n  \"a\": 1,\n  \"b\": {\"c\": 1, \"c\": 2},\n  \"a\": 3\n}",
+			expected: []*StrictError{
+				{Kind: DuplicateKey, Path: "b.c", Position: Position{3, 17}},
+				{Kind: DuplicateKey, Path: "a", Position: Position{4, 3}},
+			},
+		},
+		{
+			format: "json",
+			input:  "{\n  // lenient\n  a: [1, NaN],\n  'a': -Infinity,\n}",
+			expected: []*StrictError{
+				{Kind: NonFiniteNumber, Path: "a[1]", Position: Position{3, 10}},
+				{Kind: DuplicateKey, Path: "a", Position: Position{4, 3}},
+				{Kind: NonFiniteNumber, Path: "a", Position: Position{4, 8}},
+			},
+		},
+		{
+			format: "yaml",
+			input:  "base: &b\n  x: 1\nweb:\n  <<: *b\n  x: 2\n  1: one\n  '1': uno\nratio: .nan\n",
+			expected: []*StrictError{
+				{Kind: NonStringKey, Path: "web.1", Position: Position{6, 3}},
+				{Kind: DuplicateKey, Path: "web.1", Position: Position{7, 3}},
+				{Kind: NonFiniteNumber, Path: "ratio", Position: Position{8, 8}},
+			},
+		},
+		{
+			format: "toml",
+			input:  "a = inf\n[b]\nc = [1.0, -nan]\n",
+			expected: []*StrictError{
+				{Kind: NonFiniteNumber, Path: "a", Position: Position{1, 5}},
+				{Kind: NonFiniteNumber, Path: "b.c[1]", Position: Position{3, 11}},
+			},
+		},
+		{
+			format: "dotenv",
+			input:  "A=1\n# comment\nexport A=2\n",
+			expected: []*StrictError{
+				{Kind: DuplicateKey, Path: "A", Position: Position{Line: 3}},
+			},
+		},
+		{
+			format: "properties",
+			input:  "a.b=1\na.b : 2\n",
+			expected: []*StrictError{
+				{Kind: DuplicateKey, Path: "a.b", Position: Position{Line: 2}},
+			},
+		},
+		{
+			format: "ini",
+			input:  "db = x\n[db]\nhost = a\nhost = b\n[web]\n[db]\n",
+			expected: []*StrictError{
+				{Kind: DuplicateKey, Path: "db", Position: Position{Line: 2}},
+				{Kind: DuplicateKey, Path: "db.host", Position: Position{Line: 4}},
+				{Kind: DuplicateKey, Path: "db", Position: Position{Line: 6}},
+			},
+		},
+		{
+			format: "xml",
+			input:  "<root xmlns:a=\"urn:a\" xmlns:b=\"urn:b\">\n  <item/>\n  <item a:id=\"1\" b:id=\"2\"/>\n</root>",
+			expected: []*StrictError{
+				{Kind: DuplicateKey, Path: "root.item[1].@id", Position: Position{3, 3}},
+			},
+		},
+		{
+			format: "hcl",
+			input:  "tags = {\n  env = \"dev\"\n  1 = \"one\"\n  \"env\" = \"prod\"\n}\nrule { ports = [{ a = 1, a = 2 }] }\nrule {}\nref = { a = var.x, a = 2 }\n",
+			expected: []*StrictError{
+				{Kind: NonStringKey, Path: "tags.1", Position: Position{3, 3}},
+				{Kind: DuplicateKey, Path: "tags.env", Position: Position{4, 3}},
+				{Kind: DuplicateKey, Path: "rule[0].ports[0].a", Position: Position{6, 26}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			issues, err := Check([]byte(tt.input), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, issues)
+		})
+	}
+
+	issues, err := Check([]byte(`{"a": 1, "b": 2}`), "json")
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	_, err = Check([]byte("a: [1\n"), "yaml")
+	assert.Error(t, err)
+
+	Register("arrows", []string{".arrows"}, keyValueDecoder{})
+	_, err = Check([]byte("a -> 1\n"), "arrows")
+	assert.ErrorIs(t, err, ErrStrictUnsupported)
+}
+
+func TestParseStrict(t *testing.T) {
+	_, err := ParseStrict(strings.NewReader("{\"a\": 1, \"a\": 2}"), "json")
+	require.Error(t, err)
+
+	var strictErr *StrictError
+	require.ErrorAs(t, err, &strictErr)
+	assert.Equal(t, DuplicateKey, strictErr.Kind)
+	assert.Equal(t, "a", strictErr.Path)
+	assert.Equal(t, `line 1, column 10: duplicate key "a"`, err.Error())
+
+	result, err := ParseStrict(strings.NewReader("a: 1\n"), "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 1}, result)
+
+	_, err = ParseStrict(strings.NewReader("a: 1\na: 2\n"), "yaml")
+	require.ErrorAs(t, err, &strictErr)
+
+	data := "a: 1\nb: {c: 1, c: 2}\na: 3\n"
+	_, err = Parse(strings.NewReader(data), "yaml")
+	require.ErrorContains(t, err, `mapping key "a" already defined`)
+
+	result, err = YAMLDecoder{LastKeyWins: true}.Decode([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 3, "b": map[string]any{"c": 2}}, result)
+}
+
+func TestParse_ExactNumbers(t *testing.T) {
+	tests := []struct {
+		format   string
+		input    string
+		expected map[string]any
+	}{
+		{
+			format: "json",
+			input:  `{"id": 9007199254740993, "ratio": 0.1, "price": 19.990000000000000001, "huge": 1e400, "list": [18446744073709551616]}`,
+			expected: map[string]any{
+				"id": json.Number("9007199254740993"), "ratio": 0.1, "price": json.Number("19.990000000000000001"),
+				"huge": json.Number("1e400"), "list": []any{json.Number("18446744073709551616")},
+			},
+		},
+		{
+			format: "json5",
+			input:  "{id: 9007199254740993, mask: 0x1FFFFFFFFFFFFFFFFF, half: .5, neg: -9007199254740993.}",
+			expected: map[string]any{
+				"id": json.Number("9007199254740993"), "mask": json.Number("590295810358705651711"),
+				"half": 0.5, "neg": json.Number("-9007199254740993"),
+			},
+		},
+		{
+			format: "yaml",
+			input: "id: 9007199254740993\nbig: 18446744073709551616\nmask: 0x1FFFFFFFFFFFFFFFFF\n" +
+				"price: 19.990000000000000001\nhuge: 1e400\nquoted: '1e400'\ntagged: !!str 18446744073709551616\n" +
+				"base: &b {n: -18446744073709551616, m: 1}\nderived:\n  <<: *b\n  m: 2\n",
+			expected: map[string]any{
+				"id": 9007199254740993, "big": json.Number("18446744073709551616"), "mask": json.Number("590295810358705651711"),
+				"price": json.Number("19.990000000000000001"), "huge": json.Number("1e400"),
+				"quoted": "1e400", "tagged": "18446744073709551616",
+				"base":    map[string]any{"n": json.Number("-18446744073709551616"), "m": 1},
+				"derived": map[string]any{"n": json.Number("-18446744073709551616"), "m": 2},
+			},
+		},
+		{
+			format:   "hcl",
+			input:    "id = 9007199254740993\nbig = 18446744073709551616\nratio = 0.1\n",
+			expected: map[string]any{"id": int64(9007199254740993), "big": json.Number("18446744073709551616"), "ratio": 0.1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			result, err := Parse(strings.NewReader(tt.input), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	result, err := (INIDecoder{CoerceTypes: true}).Decode([]byte("id = 9007199254740993\nratio = 0.5\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": int64(9007199254740993), "ratio": 0.5}, result)
+
+	_, err = Parse(strings.NewReader("id = 9223372036854775808\n"), "toml")
+	assert.ErrorContains(t, err, "invalid TOML")
+}
+
+func TestPositions_ExactNumbers(t *testing.T) {
+	positions, err := Positions([]byte(`{"huge": 1e400, "id": 9007199254740993}`), "json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Position{"huge": {1, 2}, "id": {1, 17}}, positions)
+}
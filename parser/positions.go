@@ -0,0 +1,358 @@
+package parser
+
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+
+// Position is where a value starts in its file: for a map entry the
+// position of its key, for a list element that of the element. Line and
+// Column count from 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+
+// Positions reports where each value of data, parsed as format, starts,
+// keyed by path in the syntax of diff paths, e.g. "servers[0].port". JSON,
+// YAML and TOML are supported; other formats, and JSON that only parses
+// leniently as JSON5, give no positions.
+func Positions(data []byte, format string) (map[string]Position, error) {
+	m, err := inspect(data, format)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.positions, nil
+}
+
+
+// KeyOrder reports the keys of each map in data, parsed as format, in the
+// order they appear in the document, keyed by the path of the map ("" for
+// the top level). It supports the same formats as Positions.
+func KeyOrder(data []byte, format string) (map[string][]string, error) {
+	m, err := inspect(data, format)
+	if m == nil || err != nil {
+		return nil, err
+	}
+	return m.order, nil
+}
+
+
+// sourceMap is what inspect learns about the layout of a document, along
+// with the ambiguities Check reports for it.
+type sourceMap struct {
+	positions map[string]Position
+	order     map[string][]string
+	issues    []*StrictError
+}
+
+
+func newSourceMap() *sourceMap {
+	return &sourceMap{positions: make(map[string]Position), order: make(map[string][]string)}
+}
+
+
+// key records that key of the map at parent appears at pos and returns its
+// path. A key seen before keeps its place in the order, and its first
+// position unless replace is set.
+func (m *sourceMap) key(parent, key string, pos Position, replace bool) string {
+	path := keyPath(parent, key)
+	if _, seen := m.positions[path]; !seen {
+		m.order[parent] = append(m.order[parent], key)
+	} else if !replace {
+		return path
+	}
+	m.positions[path] = pos
+	return path
+}
+
+
+func (m *sourceMap) report(kind StrictKind, path string, pos Position) {
+	m.issues = append(m.issues, &StrictError{Kind: kind, Path: path, Position: pos})
+}
+
+
+func inspect(data []byte, format string) (*sourceMap, error) {
+	f, ok := lookupFormat(format)
+	if !ok {
+		return nil, unsupportedFormatError(format)
+	}
+
+	switch f.name {
+	case "json":
+		if !json.Valid(data) {
+			return nil, nil
+		}
+		return jsonSourceMap(data)
+	case "yaml":
+		return yamlSourceMap(data)
+	case "toml":
+		return tomlSourceMap(data)
+	default:
+		return nil, nil
+	}
+}
+
+
+func yamlSourceMap(data []byte) (*sourceMap, error) {
+	docs, err := yamlDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newSourceMap()
+	for i, doc := range docs {
+		if path := documentPath(docs, i); path != "" {
+			m.positions[path] = yamlPosition(doc)
+		}
+		m.walkYAML(doc, documentPath(docs, i))
+	}
+	return m, nil
+}
+
+
+// walkYAML records the layout below node. Keys merged in with "<<" are
+// placed at the merge key; the contents of an alias are left out, so they
+// fall back to the position of the alias itself, and are checked only at
+// the anchor.
+func (m *sourceMap) walkYAML(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var f float64
+		if node.ShortTag() == "!!float" && node.Decode(&f) == nil && isNonFinite(f) {
+			m.report(NonFiniteNumber, path, yamlPosition(node))
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			m.positions[indexPath(path, i)] = yamlPosition(item)
+			m.walkYAML(item, indexPath(path, i))
+		}
+
+	case yaml.MappingNode:
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if isMergeKey(key) {
+				for _, k := range mappingKeys(value) {
+					m.key(path, k, yamlPosition(key), false)
+				}
+				continue
+			}
+
+			child := keyPath(path, key.Value)
+			if resolved := resolveAlias(key); resolved.Kind != yaml.ScalarNode || resolved.ShortTag() != "!!str" {
+				m.report(NonStringKey, child, yamlPosition(key))
+			}
+			if seen[key.Value] {
+				m.report(DuplicateKey, child, yamlPosition(key))
+			}
+			seen[key.Value] = true
+			m.walkYAML(value, m.key(path, key.Value, yamlPosition(key), true))
+		}
+	}
+}
+
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+
+func yamlPosition(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column}
+}
+
+
+func jsonSourceMap(data []byte) (*sourceMap, error) {
+	w := jsonWalker{
+		dec:  json.NewDecoder(bytes.NewReader(data)),
+		data: data,
+		m:    newSourceMap(),
+	}
+	w.dec.UseNumber()
+	if err := w.value(""); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return w.m, nil
+}
+
+
+type jsonWalker struct {
+	dec  *json.Decoder
+	data []byte
+	m    *sourceMap
+}
+
+
+// start is the position of the next token, past the whitespace and
+// separators the decoder has not consumed yet.
+func (w *jsonWalker) start() Position {
+	offset := int(w.dec.InputOffset())
+	for offset < len(w.data) && bytes.IndexByte([]byte(" \t\r\n,:"), w.data[offset]) >= 0 {
+		offset++
+	}
+	return offsetPosition(w.data, offset)
+}
+
+
+// next reads the next token along with the position it starts at.
+func (w *jsonWalker) next() (json.Token, Position, error) {
+	pos := w.start()
+	tok, err := w.dec.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return tok, pos, err
+}
+
+
+func (w *jsonWalker) value(path string) error {
+	tok, _, err := w.next()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]bool)
+		for w.dec.More() {
+			key, pos, err := w.next()
+			if err != nil {
+				return err
+			}
+			name := fmt.Sprint(key)
+			if seen[name] {
+				w.m.report(DuplicateKey, keyPath(path, name), pos)
+			}
+			seen[name] = true
+			child := w.m.key(path, name, pos, true)
+			if err := w.value(child); err != nil {
+				return err
+			}
+		}
+		_, _, err = w.next()
+
+	case json.Delim('['):
+		for i := 0; w.dec.More(); i++ {
+			w.m.positions[indexPath(path, i)] = w.start()
+			if err := w.value(indexPath(path, i)); err != nil {
+				return err
+			}
+		}
+		_, _, err = w.next()
+	}
+	return err
+}
+
+
+func tomlSourceMap(data []byte) (*sourceMap, error) {
+	p := &unstable.Parser{}
+	p.Reset(data)
+
+	w := tomlWalker{parser: p, arrays: make(map[string]int), m: newSourceMap()}
+	table := ""
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table:
+			table = w.key("", expr.Key(), false)
+		case unstable.ArrayTable:
+			table = w.key("", expr.Key(), true)
+		case unstable.KeyValue:
+			w.keyValue(table, expr)
+		}
+	}
+	if err := p.Error(); err != nil {
+		return nil, fmt.Errorf("invalid TOML: %w", err)
+	}
+	return w.m, nil
+}
+
+
+type tomlWalker struct {
+	parser *unstable.Parser
+	arrays map[string]int
+	m      *sourceMap
+}
+
+
+// key resolves a dotted key below parent, recording where each of its parts
+// first appears. Parts naming an array of tables refer to its latest
+// element; with arrayTable the key itself starts a new element.
+func (w *tomlWalker) key(parent string, it unstable.Iterator, arrayTable bool) string {
+	path := parent
+	for it.Next() {
+		node := it.Node()
+		path = w.m.key(path, string(node.Data), w.position(node), false)
+
+		if n, ok := w.arrays[path]; ok && !(arrayTable && it.IsLast()) {
+			path = indexPath(path, n-1)
+		}
+		if arrayTable && it.IsLast() {
+			n := w.arrays[path]
+			w.arrays[path] = n + 1
+			path = indexPath(path, n)
+			w.m.positions[path] = w.position(node)
+		}
+	}
+	return path
+}
+
+
+func (w *tomlWalker) keyValue(table string, kv *unstable.Node) {
+	path := w.key(table, kv.Key(), false)
+	w.value(path, kv.Value())
+}
+
+
+func (w *tomlWalker) value(path string, node *unstable.Node) {
+	switch node.Kind {
+	case unstable.Array:
+		it := node.Children()
+		for i := 0; it.Next(); i++ {
+			elem := it.Node()
+			if elem.Raw.Length > 0 {
+				w.m.positions[indexPath(path, i)] = w.position(elem)
+			}
+			w.value(indexPath(path, i), elem)
+		}
+	case unstable.InlineTable:
+		it := node.Children()
+		for it.Next() {
+			w.keyValue(path, it.Node())
+		}
+	case unstable.Float:
+		switch strings.TrimLeft(string(node.Data), "+-") {
+		case "nan", "inf":
+			w.m.report(NonFiniteNumber, path, w.position(node))
+		}
+	}
+}
+
+
+func (w *tomlWalker) position(node *unstable.Node) Position {
+	start := w.parser.Shape(node.Raw).Start
+	return Position{Line: start.Line, Column: start.Column}
+}
+
+
+func offsetPosition(data []byte, offset int) Position {
+	lead := data[:offset]
+	return Position{
+		Line:   bytes.Count(lead, []byte{'\n'}) + 1,
+		Column: len(lead) - bytes.LastIndexByte(lead, '\n'),
+	}
+}
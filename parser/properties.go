@@ -0,0 +1,206 @@
+package parser
+
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+
+// PropertiesDecoder reads Java .properties files as described in
+// java.util.Properties.load: '#' and '!' comments, keys separated from values
+// by '=', ':' or whitespace, lines continued with a trailing backslash, and
+// \t, \n, \r, \f and \uXXXX escapes. Values stay strings.
+type PropertiesDecoder struct {
+	// ExpandKeys splits dotted keys into nested maps, so
+	// spring.datasource.url is reported under that path by the plain
+	// formatter instead of as one flat key.
+	ExpandKeys bool
+}
+
+
+func (d PropertiesDecoder) Decode(data []byte) (map[string]any, error) {
+	return d.decode(data, nil)
+}
+
+
+// decode parses data, calling onKey, if set, with each key and the line it
+// starts on before the key is stored.
+func (d PropertiesDecoder) decode(data []byte, onKey func(key string, line int)) (map[string]any, error) {
+	result := make(map[string]any)
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		logical := strings.TrimLeft(lines[i], " \t\f")
+		if logical == "" || logical[0] == '#' || logical[0] == '!' {
+			continue
+		}
+
+		for continues(logical) && i+1 < len(lines) {
+			i++
+			logical = logical[:len(logical)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continues(logical) {
+			logical = logical[:len(logical)-1]
+		}
+
+		rawKey, rawValue := splitProperty(logical)
+		key, err := unescapeProperty(rawKey)
+		if err == nil && onKey != nil {
+			onKey(key, lineNo)
+		}
+		if err == nil {
+			var value string
+			if value, err = unescapeProperty(rawValue); err == nil {
+				err = d.set(result, key, value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid properties: line %d: %w", lineNo, err)
+		}
+	}
+
+	return result, nil
+}
+
+
+// Detect accepts content whose every meaningful line is a comment or a
+// key followed by '=' or ':'.
+func (PropertiesDecoder) Detect(data []byte) bool {
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == '!':
+		case line[0] == '[' || line[0] == ';':
+			return false
+		default:
+			sep := strings.IndexAny(line, "=:")
+			if sep <= 0 || strings.ContainsAny(strings.TrimSpace(line[:sep]), " \t") {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+
+func (d PropertiesDecoder) set(result map[string]any, key, value string) error {
+	if !d.ExpandKeys {
+		result[key] = value
+		return nil
+	}
+
+	parts := strings.Split(key, ".")
+	node := result
+	for i, part := range parts[:len(parts)-1] {
+		child, exists := node[part]
+		if !exists {
+			next := make(map[string]any)
+			node[part] = next
+			node = next
+			continue
+		}
+		next, ok := child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("key %s conflicts with %s", key, strings.Join(parts[:i+1], "."))
+		}
+		node = next
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := node[last].(map[string]any); ok {
+		return fmt.Errorf("key %s conflicts with nested keys under it", key)
+	}
+	node[last] = value
+	return nil
+}
+
+
+// continues reports whether a line ends with an odd number of backslashes,
+// i.e. with an unescaped line continuation.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+
+func splitProperty(line string) (string, string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+
+	key := line[:end]
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return key, rest
+}
+
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			r := rune(code)
+			i += 4
+			// Characters outside the BMP are written as a UTF-16 surrogate
+			// pair, \uD83D\uDE00; a lone surrogate becomes U+FFFD.
+			if utf16.IsSurrogate(r) && i+7 <= len(s) && s[i+1:i+3] == "\\u" {
+				if low, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+					if pair := utf16.DecodeRune(r, rune(low)); pair != unicode.ReplacementChar {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
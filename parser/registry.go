@@ -0,0 +1,178 @@
+package parser
+
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+
+// Decoder turns the raw bytes of a document into the tree BuildDiff
+// compares.
+type Decoder interface {
+	Decode(data []byte) (map[string]any, error)
+}
+
+
+// DecoderFunc adapts a plain function to the Decoder interface.
+type DecoderFunc func(data []byte) (map[string]any, error)
+
+
+func (f DecoderFunc) Decode(data []byte) (map[string]any, error) {
+	return f(data)
+}
+
+
+// Detector may be implemented by a Decoder to take part in DetectFormat
+// with a cheaper or stricter check than a trial decode. A decoder that
+// should never be picked from content alone can return false.
+type Detector interface {
+	Detect(data []byte) bool
+}
+
+
+// DocumentsDecoder may be implemented by a Decoder whose format can hold
+// several documents in one file, as YAML does, to have ParseDocuments return
+// them one by one.
+type DocumentsDecoder interface {
+	DecodeDocuments(data []byte) ([]map[string]any, error)
+}
+
+
+type registeredFormat struct {
+	name    string
+	exts    []string
+	decoder Decoder
+}
+
+
+var (
+	registryMu sync.RWMutex
+	registry   []registeredFormat
+)
+
+
+// Register makes a format available to ParseFile, Parse and DetectFormat.
+// exts are file extensions including the dot, e.g. ".yml". Registering a
+// name again replaces the earlier decoder but keeps its place in the
+// detection order, which is the order of first registration.
+func Register(name string, exts []string, dec Decoder) {
+	if name == "" || dec == nil {
+		panic("parser: Register needs a format name and a decoder")
+	}
+
+	f := registeredFormat{name: strings.ToLower(name), decoder: dec}
+	for _, ext := range exts {
+		f.exts = append(f.exts, strings.ToLower(ext))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for i := range registry {
+		if registry[i].name == f.name {
+			registry[i] = f
+			return
+		}
+	}
+	registry = append(registry, f)
+}
+
+
+// Formats returns the names of the registered formats in detection order.
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for _, f := range registry {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+
+// Extensions returns the file extensions registered for a format, so a
+// caller can register a differently configured decoder under the same name.
+func Extensions(name string) []string {
+	f, ok := lookupFormat(name)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), f.exts...)
+}
+
+
+// FormatName returns the name a format is registered under, which name may
+// also give in another case or as one of its extensions without the dot.
+func FormatName(name string) (string, bool) {
+	f, ok := lookupFormat(name)
+	return f.name, ok
+}
+
+
+// lookupFormat finds a format by name. An extension without the dot works
+// as an alias, so "yml" means yaml.
+func lookupFormat(name string) (registeredFormat, bool) {
+	name = strings.ToLower(name)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, f := range registry {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range registry {
+		for _, ext := range f.exts {
+			if ext == "."+name {
+				return f, true
+			}
+		}
+	}
+	return registeredFormat{}, false
+}
+
+
+func formatForExt(ext string) (string, bool) {
+	ext = strings.ToLower(ext)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, f := range registry {
+		for _, e := range f.exts {
+			if e == ext {
+				return f.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+
+func registeredExts() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var exts []string
+	for _, f := range registry {
+		exts = append(exts, f.exts...)
+	}
+	return exts
+}
+
+
+func snapshot() []registeredFormat {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return append([]registeredFormat(nil), registry...)
+}
+
+
+func unsupportedFormatError(format string) error {
+	return fmt.Errorf("unsupported input format: %s (expected %s)", format, strings.Join(Formats(), ", "))
+}
@@ -0,0 +1,416 @@
+package parser
+
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+
+// StrictKind is the kind of ambiguity a StrictError reports.
+type StrictKind int
+
+
+const (
+	// DuplicateKey is a key repeated in the same map. Decoders keep the
+	// last value, or fail, depending on the format.
+	DuplicateKey StrictKind = iota + 1
+	// NonStringKey is a YAML key that is a number, boolean, null or
+	// collection and is converted to a string to fit the tree.
+	NonStringKey
+	// NonFiniteNumber is a NaN or infinite number, which JSON cannot
+	// represent and which never compares equal to itself.
+	NonFiniteNumber
+)
+
+
+func (k StrictKind) String() string {
+	switch k {
+	case DuplicateKey:
+		return "duplicate key"
+	case NonStringKey:
+		return "non-string key"
+	case NonFiniteNumber:
+		return "non-finite number"
+	default:
+		return fmt.Sprintf("StrictKind(%d)", int(k))
+	}
+}
+
+
+// StrictError is an ambiguity in a document that the decoders resolve
+// silently. Path uses the syntax of diff paths; Position is zero when the
+// format does not track it, and Column is zero for line-based formats.
+type StrictError struct {
+	Kind     StrictKind
+	Path     string
+	Position Position
+}
+
+
+func (e *StrictError) Error() string {
+	msg := fmt.Sprintf("%s %q", e.Kind, e.Path)
+	switch {
+	case e.Position.Line == 0:
+		return msg
+	case e.Position.Column == 0:
+		return fmt.Sprintf("line %d: %s", e.Position.Line, msg)
+	default:
+		return fmt.Sprintf("line %d, column %d: %s", e.Position.Line, e.Position.Column, msg)
+	}
+}
+
+
+// Checker may be implemented by a Decoder to take part in Check. Check
+// reports the ambiguities found in data and fails only when data cannot be
+// parsed.
+type Checker interface {
+	Check(data []byte) ([]*StrictError, error)
+}
+
+
+// ErrStrictUnsupported is returned by Check for formats whose decoder is not
+// a Checker.
+var ErrStrictUnsupported = errors.New("strict checking is not supported")
+
+
+// Check reports the ambiguities in data, parsed as format, in the order they
+// appear: duplicate keys, non-string keys and NaN or infinite numbers.
+// Formats whose decoder is not a Checker fail with ErrStrictUnsupported
+// rather than pass unchecked.
+func Check(data []byte, format string) ([]*StrictError, error) {
+	f, ok := lookupFormat(format)
+	if !ok {
+		return nil, unsupportedFormatError(format)
+	}
+	issues, err := CheckDecoder(f.decoder, data)
+	if errors.Is(err, ErrStrictUnsupported) {
+		return nil, fmt.Errorf("%w for %s input", err, f.name)
+	}
+	return issues, err
+}
+
+
+// CheckDecoder is Check with dec in place of a registered decoder. It fails
+// with ErrStrictUnsupported itself when dec is not a Checker.
+func CheckDecoder(dec Decoder, data []byte) ([]*StrictError, error) {
+	c, ok := dec.(Checker)
+	if !ok {
+		return nil, ErrStrictUnsupported
+	}
+	return c.Check(data)
+}
+
+
+// ParseStrict is Parse that fails on the ambiguities Check reports. The
+// error joins one *StrictError per ambiguity, so callers can inspect them
+// with errors.As.
+func ParseStrict(r io.Reader, format string) (map[string]any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if format == "" {
+		if format, err = DetectFormat(data); err != nil {
+			return nil, err
+		}
+	}
+	issues, err := Check(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := StrictErrors(issues); err != nil {
+		return nil, err
+	}
+	return parse(data, format)
+}
+
+
+// StrictErrors joins issues into a single error, or returns nil if there
+// are none.
+func StrictErrors(issues []*StrictError) error {
+	errs := make([]error, len(issues))
+	for i, issue := range issues {
+		errs[i] = issue
+	}
+	return errors.Join(errs...)
+}
+
+
+func (jsonDecoder) Check(data []byte) ([]*StrictError, error) {
+	if _, err := parseJSON(data); err != nil {
+		return JSON5Decoder{}.Check(data)
+	}
+	m, err := jsonSourceMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.issues, nil
+}
+
+
+func (JSON5Decoder) Check(data []byte) ([]*StrictError, error) {
+	p := &json5Parser{src: string(data), strict: true}
+	if _, err := p.document(); err != nil {
+		return nil, err
+	}
+	return p.issues, nil
+}
+
+
+func (YAMLDecoder) Check(data []byte) ([]*StrictError, error) {
+	m, err := yamlSourceMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.issues, nil
+}
+
+
+// Check only has NaN and infinite floats to report, since TOML forbids
+// duplicate keys itself.
+func (tomlDecoder) Check(data []byte) ([]*StrictError, error) {
+	m, err := tomlSourceMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return m.issues, nil
+}
+
+
+func (d DotenvDecoder) Check(data []byte) ([]*StrictError, error) {
+	c := newKeyChecker()
+	if _, err := d.decode(data, func(key string, line int) {
+		if d.NestSeparator != "" {
+			key = strings.ReplaceAll(key, d.NestSeparator, ".")
+		}
+		c.key(key, line)
+	}); err != nil {
+		return nil, err
+	}
+	return c.issues, nil
+}
+
+
+func (d PropertiesDecoder) Check(data []byte) ([]*StrictError, error) {
+	c := newKeyChecker()
+	if _, err := d.decode(data, c.key); err != nil {
+		return nil, err
+	}
+	return c.issues, nil
+}
+
+
+// Check reports keys repeated within a section, which the decoder collects
+// into a list, and sections that are opened twice or share their name with a
+// key before the first section.
+func (d INIDecoder) Check(data []byte) ([]*StrictError, error) {
+	c := newKeyChecker()
+	if _, err := d.decode(data, c.key); err != nil {
+		return nil, err
+	}
+	return c.issues, nil
+}
+
+
+// Check reports attributes of one element that have the same name once the
+// namespace prefix is dropped, such as a:id and b:id, of which the decoder
+// keeps the last.
+func (d XMLDecoder) Check(data []byte) ([]*StrictError, error) {
+	if _, err := d.Decode(data); err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*xmlCheckElement
+	var root *xmlCheckElement
+	for {
+		line, column := dec.InputPos()
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			el := &xmlCheckElement{name: tok.Name.Local, attrs: tok.Attr, pos: Position{Line: line, Column: column}}
+			if len(stack) == 0 {
+				root = el
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, el)
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	var issues []*StrictError
+	root.check(root.name, &issues)
+	return issues, nil
+}
+
+
+// xmlCheckElement is an element as XMLDecoder.Check sees it, kept until the
+// document is read so that the paths of repeated elements get their index.
+type xmlCheckElement struct {
+	name     string
+	attrs    []xml.Attr
+	pos      Position
+	children []*xmlCheckElement
+}
+
+
+func (el *xmlCheckElement) check(path string, issues *[]*StrictError) {
+	seen := make(map[string]bool, len(el.attrs))
+	for _, attr := range el.attrs {
+		name := "@" + xmlAttrName(attr.Name)
+		if seen[name] {
+			*issues = append(*issues, &StrictError{Kind: DuplicateKey, Path: keyPath(path, name), Position: el.pos})
+		}
+		seen[name] = true
+	}
+
+	count := make(map[string]int, len(el.children))
+	for _, child := range el.children {
+		count[child.name]++
+	}
+	index := make(map[string]int, len(count))
+	for _, child := range el.children {
+		childPath := keyPath(path, child.name)
+		if count[child.name] > 1 {
+			childPath = indexPath(childPath, index[child.name])
+			index[child.name]++
+		}
+		child.check(childPath, issues)
+	}
+}
+
+
+// Check reports the keys of object expressions ({ a = 1, a = 2 }) that are
+// repeated, of which the decoder keeps the last, or are not strings, such as
+// { 1 = "x" }, and are converted. HCL itself rejects repeated attributes.
+func (HCLDecoder) Check(data []byte) ([]*StrictError, error) {
+	file, diags := hclsyntax.ParseConfig(data, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid HCL: %w", diags)
+	}
+	var issues []*StrictError
+	hclCheckBody(file.Body.(*hclsyntax.Body), "", &issues)
+	return issues, nil
+}
+
+
+func hclCheckBody(body *hclsyntax.Body, path string, issues *[]*StrictError) {
+	for _, attr := range sortedAttributes(body) {
+		if _, diags := attr.Expr.Value(nil); diags.HasErrors() {
+			continue // kept as source text
+		}
+		hclCheckExpr(attr.Expr, keyPath(path, attr.Name), issues)
+	}
+
+	paths := make([]string, len(body.Blocks))
+	count := make(map[string]int, len(body.Blocks))
+	for i, block := range body.Blocks {
+		paths[i] = path
+		for _, part := range append([]string{block.Type}, block.Labels...) {
+			paths[i] = keyPath(paths[i], part)
+		}
+		count[paths[i]]++
+	}
+	index := make(map[string]int, len(count))
+	for i, block := range body.Blocks {
+		blockPath := paths[i]
+		if count[blockPath] > 1 {
+			blockPath = indexPath(blockPath, index[paths[i]])
+			index[paths[i]]++
+		}
+		hclCheckBody(block.Body, blockPath, issues)
+	}
+}
+
+
+// sortedAttributes lists the attributes of body in the order they appear.
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	slices.SortFunc(attrs, func(a, b *hclsyntax.Attribute) int {
+		return a.SrcRange.Start.Byte - b.SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+
+func hclCheckExpr(expr hclsyntax.Expression, path string, issues *[]*StrictError) {
+	switch e := expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		for i, item := range e.Exprs {
+			hclCheckExpr(item, indexPath(path, i), issues)
+		}
+
+	case *hclsyntax.ObjectConsExpr:
+		seen := make(map[string]bool, len(e.Items))
+		for _, item := range e.Items {
+			key, _ := item.KeyExpr.Value(nil)
+			name, err := convert.Convert(key, cty.String)
+			if err != nil {
+				continue
+			}
+			itemPath := keyPath(path, name.AsString())
+			pos := item.KeyExpr.Range().Start
+			at := Position{Line: pos.Line, Column: pos.Column}
+			if key.Type() != cty.String {
+				*issues = append(*issues, &StrictError{Kind: NonStringKey, Path: itemPath, Position: at})
+			}
+			if seen[name.AsString()] {
+				*issues = append(*issues, &StrictError{Kind: DuplicateKey, Path: itemPath, Position: at})
+			}
+			seen[name.AsString()] = true
+			hclCheckExpr(item.ValueExpr, itemPath, issues)
+		}
+	}
+}
+
+
+// keyChecker finds the repeated keys of the line-based formats, whose
+// documents are a single flat list of keys.
+type keyChecker struct {
+	seen   map[string]bool
+	issues []*StrictError
+}
+
+
+func newKeyChecker() *keyChecker {
+	return &keyChecker{seen: make(map[string]bool)}
+}
+
+
+func (c *keyChecker) key(key string, line int) {
+	if c.seen[key] {
+		c.issues = append(c.issues, &StrictError{Kind: DuplicateKey, Path: key, Position: Position{Line: line}})
+	}
+	c.seen[key] = true
+}
+
+
+func isNonFinite(f float64) bool {
+	return math.IsNaN(f) || math.IsInf(f, 0)
+}
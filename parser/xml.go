@@ -0,0 +1,126 @@
+package parser
+
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+
+// XMLDecoder reads XML documents such as a Maven pom.xml or a .NET
+// app.config. The root element becomes the single top-level key, and each
+// element maps to a value as follows:
+//
+//   - an element with neither attributes nor child elements is its text;
+//   - otherwise it is a map with an "@name" key per attribute, a key per
+//     child element and, when it has non-blank text, a "#text" key;
+//   - a child element repeated under the same parent becomes a list.
+//
+// Names are taken without their namespace prefix, except for xmlns
+// declarations which stay "@xmlns:prefix". Values are strings; comments and
+// processing instructions are dropped.
+type XMLDecoder struct{}
+
+
+// xmlElement is an element being read, with its content collected until its
+// end tag.
+type xmlElement struct {
+	name     string
+	fields   map[string]any
+	text     strings.Builder
+	children bool
+}
+
+
+func (XMLDecoder) Decode(data []byte) (map[string]any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*xmlElement
+	var result map[string]any
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && result != nil {
+				return nil, fmt.Errorf("invalid XML: more than one root element")
+			}
+			el := &xmlElement{name: tok.Name.Local, fields: make(map[string]any)}
+			for _, attr := range tok.Attr {
+				el.fields["@"+xmlAttrName(attr.Name)] = attr.Value
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].children = true
+			}
+			stack = append(stack, el)
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+
+		case xml.EndElement:
+			el := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if len(stack) == 0 {
+				result = map[string]any{el.name: el.value()}
+				continue
+			}
+			parent := stack[len(stack)-1].fields
+			switch existing := parent[el.name].(type) {
+			case nil:
+				parent[el.name] = el.value()
+			case []any:
+				parent[el.name] = append(existing, el.value())
+			default:
+				parent[el.name] = []any{existing, el.value()}
+			}
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("invalid XML: no root element")
+	}
+	return result, nil
+}
+
+
+// Detect accepts content that starts with '<' and is well-formed XML.
+func (d XMLDecoder) Detect(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return false
+	}
+	_, err := d.Decode(data)
+	return err == nil
+}
+
+
+func (el *xmlElement) value() any {
+	text := strings.TrimSpace(el.text.String())
+	if len(el.fields) == 0 && !el.children {
+		return text
+	}
+	if text != "" {
+		el.fields["#text"] = text
+	}
+	return el.fields
+}
+
+
+func xmlAttrName(name xml.Name) string {
+	if name.Space == "xmlns" {
+		return "xmlns:" + name.Local
+	}
+	return name.Local
+}